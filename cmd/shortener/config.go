@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/AsakoKabe/go-yandex-shortener/config"
+)
+
+const configUsage = "usage: shortener [flags] config print [json|yaml]"
+
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 || len(args) > 2 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+	format := ""
+	if len(args) == 2 {
+		format = args[1]
+	}
+	return cfg.Print(os.Stdout, format)
+}
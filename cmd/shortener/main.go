@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 
 	"github.com/AsakoKabe/go-yandex-shortener/config"
@@ -10,6 +12,13 @@ import (
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
+		log.Fatalf("invalid config: %s", err.Error())
+	}
+
+	if flag.NArg() > 0 {
+		if err := runCommand(cfg, flag.Args()); err != nil {
+			log.Fatalf("%s", err.Error())
+		}
 		return
 	}
 
@@ -23,3 +32,14 @@ func main() {
 		log.Fatalf("%s", err.Error())
 	}
 }
+
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "config":
+		return runConfig(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
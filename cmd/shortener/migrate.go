@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/connection"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/migrations"
+)
+
+const migrateUsage = "usage: shortener [flags] migrate up|down|status"
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.DatabaseDSN == "" {
+		return errors.New("database dsn is required for migrations")
+	}
+
+	pool, err := connection.NewDBPool(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := migrations.NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
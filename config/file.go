@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/caarlos0/env/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile применяет к cfg значения из json или yaml файла и возвращает заданные в нём ключи
+func loadFile(path string, cfg *Config) (map[string]struct{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		// без UseNumber большие целые превращаются в float64 и печатаются как 1e+06
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+
+	known := fileKeys()
+	keys := make(map[string]struct{}, len(raw))
+	values := make(map[string]string, len(raw))
+	var unknown []string
+	for key, value := range raw {
+		envName, ok := known[key]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		s, err := fileValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s, key %s: %w", path, key, err)
+		}
+		keys[key] = struct{}{}
+		values[envName] = s
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(unknown, ", "))
+	}
+
+	// значения из файла разбираются так же, как переменные окружения
+	err = env.ParseWithOptions(cfg, env.Options{Environment: values})
+	if err == nil {
+		clearEmptyStoragePath(cfg, func(key string) (string, bool) {
+			value, ok := values[key]
+			return value, ok
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// fileKeys сопоставляет ключи файла именам переменных окружения
+func fileKeys() map[string]string {
+	keys := make(map[string]string)
+	for _, envName := range envNames() {
+		if envName == "CONFIG" {
+			continue
+		}
+		keys[strings.ToLower(envName)] = envName
+	}
+	return keys
+}
+
+func envNames() []string {
+	t := reflect.TypeOf(Config{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, t.Field(i).Tag.Get("env"))
+	}
+	return names
+}
+
+func fileValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool, int, int64:
+		return fmt.Sprint(v), nil
+	case []any:
+		// списки (например trusted_proxies) можно задать массивом
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
@@ -2,13 +2,48 @@ package config
 
 import (
 	"flag"
-)
+	"time"
 
-func parseFlag(c *Config) {
-	flag.StringVar(&c.Addr, "a", "localhost:8080", "Net address host:port")
-	flag.StringVar(&c.PrefixURL, "b", "http://localhost:8080", "short url prefix")
-	flag.StringVar(&c.FileStoragePath, "f", "/tmp/short-url-db.json", "file storage path")
-	flag.StringVar(&c.DatabaseDSN, "d", "", "db path")
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+)
 
-	flag.Parse()
+func defineFlags(fs *flag.FlagSet, c *Config) {
+	fs.StringVar(&c.ConfigFile, "c", "", "config file (json or yaml)")
+	fs.StringVar(&c.Addr, "a", "localhost:8080", "Net address host:port")
+	fs.StringVar(&c.PrefixURL, "b", "http://localhost:8080", "short url prefix")
+	fs.StringVar(&c.GRPCAddr, "g", "", "gRPC net address host:port, empty disables gRPC")
+	fs.StringVar(&c.AdminAddr, "admin-addr", "", "admin listener host:port for /metrics and /admin/loglevel, empty serves them on the main address")
+	fs.StringVar(&c.LogLevel, "log-level", "info", "log level: debug|info|warn|error")
+	fs.StringVar(&c.LogFormat, "log-format", "json", "log format: json|console")
+	fs.StringVar(&c.TraceExporter, "trace-exporter", "none", "trace exporter: none|stdout|otlp")
+	fs.StringVar(&c.TraceEndpoint, "trace-endpoint", "", "OTLP/HTTP traces endpoint url, empty uses OTEL_EXPORTER_OTLP_* env")
+	fs.StringVar(&c.RateLimitStore, "rate-limit-store", "memory", "rate limit buckets storage: memory|postgres")
+	fs.Float64Var(&c.WriteRateLimit, "rate-limit-write-rps", 0, "requests per second per client for write endpoints, 0 disables")
+	fs.IntVar(&c.WriteRateBurst, "rate-limit-write-burst", 20, "burst of write requests per client")
+	fs.Float64Var(&c.RedirectRateLimit, "rate-limit-redirect-rps", 0, "requests per second per client for redirects, 0 disables")
+	fs.IntVar(&c.RedirectRateBurst, "rate-limit-redirect-burst", 100, "burst of redirects per client")
+	fs.StringVar(&c.APIKeys, "api-keys", "", "comma separated API keys (X-API-Key) that get their own rate limit")
+	fs.StringVar(&c.Storage, "storage", "", "url storage: memory|file|postgres, empty picks postgres with -d, file with -f, else memory")
+	fs.StringVar(&c.FileStoragePath, "f", "/tmp/short-url-db.json", "file storage path, empty keeps urls in memory")
+	fs.StringVar(&c.FileSync, "file-sync", "interval", "file storage fsync policy: always|interval|never")
+	fs.DurationVar(&c.FileSyncInterval, "file-sync-interval", time.Second, "how often file storage is fsynced with -file-sync=interval")
+	fs.StringVar(&c.DatabaseDSN, "d", "", "db path")
+	fs.StringVar(&c.SecretKey, "k", "", "secret key for signing user cookies")
+	fs.StringVar(&c.CodeStrategy, "code-strategy", "random", "short code strategy: random|sequence|hash|sqids")
+	fs.IntVar(&c.CodeLength, "code-length", 5, "short code length (minimal length for sqids)")
+	fs.StringVar(&c.CodeAlphabet, "code-alphabet", codegen.Base62Alphabet, "short code alphabet")
+	fs.StringVar(&c.CodeSalt, "code-salt", "", "salt for shuffling sqids alphabet")
+	fs.StringVar(&c.TrustedSubnet, "t", "", "trusted subnet (CIDR) allowed to call internal endpoints")
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", "", "comma separated proxies allowed to set X-Real-IP/X-Forwarded-For")
+	fs.BoolVar(&c.EnableHTTPS, "s", false, "serve https")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", "", "tls certificate file, self-signed certificate is generated if empty")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", "", "tls private key file")
+	fs.StringVar(&c.TLSMinVersion, "tls-min-version", "1.2", "minimal tls version: 1.2|1.3")
+	fs.StringVar(&c.TLSCipherSuites, "tls-cipher-suites", "", "comma separated tls cipher suites, empty uses Go defaults")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "deadline for draining requests and background workers on shutdown")
+	fs.DurationVar(&c.ReaperInterval, "reaper-interval", time.Minute, "interval of purging expired urls, 0 disables")
+	fs.IntVar(&c.CacheSize, "cache-size", 10000, "max short links kept in the redirect cache, 0 disables")
+	fs.DurationVar(&c.CacheTTL, "cache-ttl", 5*time.Minute, "how long a resolved short link stays cached")
+	fs.DurationVar(&c.CacheNegativeTTL, "cache-negative-ttl", 10*time.Second, "how long an unknown short code stays cached")
+	fs.IntVar(&c.BatchMaxSize, "batch-max-size", 1000, "max urls in one batch request, 0 disables the limit")
 }
@@ -1,27 +1,138 @@
 package config
 
 import (
-	"log"
+	"flag"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v10"
 )
 
+// Config - настройки сервиса. Источники в порядке убывания приоритета:
+// флаги командной строки, переменные окружения, файл из -c/CONFIG, значения по умолчанию.
+// Ключи файла - имена переменных окружения в нижнем регистре, например server_address.
 type Config struct {
-	Addr            string `env:"SERVER_ADDRESS"`
-	PrefixURL       string `env:"BASE_URL"`
-	FileStoragePath string `env:"FILE_STORAGE_PATH"`
-	DatabaseDSN     string `env:"DATABASE_DSN"`
+	ConfigFile        string        `env:"CONFIG"`
+	Addr              string        `env:"SERVER_ADDRESS"`
+	PrefixURL         string        `env:"BASE_URL"`
+	Storage           string        `env:"STORAGE"`
+	FileStoragePath   string        `env:"FILE_STORAGE_PATH"`
+	FileSync          string        `env:"FILE_STORAGE_SYNC"`
+	FileSyncInterval  time.Duration `env:"FILE_STORAGE_SYNC_INTERVAL"`
+	DatabaseDSN       string        `env:"DATABASE_DSN"`
+	SecretKey         string        `env:"SECRET_KEY"`
+	CodeStrategy      string        `env:"SHORT_CODE_STRATEGY"`
+	CodeLength        int           `env:"SHORT_CODE_LENGTH"`
+	CodeAlphabet      string        `env:"SHORT_CODE_ALPHABET"`
+	CodeSalt          string        `env:"SHORT_CODE_SALT"`
+	ReaperInterval    time.Duration `env:"REAPER_INTERVAL"`
+	TrustedSubnet     string        `env:"TRUSTED_SUBNET"`
+	TrustedProxies    string        `env:"TRUSTED_PROXIES"`
+	GRPCAddr          string        `env:"GRPC_ADDRESS"`
+	EnableHTTPS       bool          `env:"ENABLE_HTTPS"`
+	TLSCertFile       string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE"`
+	TLSMinVersion     string        `env:"TLS_MIN_VERSION"`
+	TLSCipherSuites   string        `env:"TLS_CIPHER_SUITES"`
+	ShutdownTimeout   time.Duration `env:"SHUTDOWN_TIMEOUT"`
+	AdminAddr         string        `env:"ADMIN_ADDRESS"`
+	LogLevel          string        `env:"LOG_LEVEL"`
+	RateLimitStore    string        `env:"RATE_LIMIT_STORE"`
+	WriteRateLimit    float64       `env:"RATE_LIMIT_WRITE_RPS"`
+	WriteRateBurst    int           `env:"RATE_LIMIT_WRITE_BURST"`
+	RedirectRateLimit float64       `env:"RATE_LIMIT_REDIRECT_RPS"`
+	RedirectRateBurst int           `env:"RATE_LIMIT_REDIRECT_BURST"`
+	APIKeys           string        `env:"API_KEYS"`
+	LogFormat         string        `env:"LOG_FORMAT"`
+	TraceExporter     string        `env:"TRACE_EXPORTER"`
+	TraceEndpoint     string        `env:"TRACE_OTLP_ENDPOINT"`
+	CacheSize         int           `env:"CACHE_SIZE"`
+	CacheTTL          time.Duration `env:"CACHE_TTL"`
+	CacheNegativeTTL  time.Duration `env:"CACHE_NEGATIVE_TTL"`
+	BatchMaxSize      int           `env:"BATCH_MAX_SIZE"`
 }
 
 func LoadConfig() (*Config, error) {
+	return load(flag.CommandLine, os.Args[1:])
+}
+
+func load(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := new(Config)
+	// те же флаги, привязанные к cfg, дают значения по умолчанию и позволяют
+	// применить явно заданные флаги поверх файла и окружения
+	defaults := flag.NewFlagSet("defaults", flag.ContinueOnError)
+	defineFlags(defaults, cfg)
+
+	defineFlags(fs, new(Config))
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
 
-	parseFlag(cfg)
+	cfg.ConfigFile = os.Getenv("CONFIG")
+	if path, ok := explicit["c"]; ok {
+		cfg.ConfigFile = path
+	}
+
+	prefixSet := false
+	if cfg.ConfigFile != "" {
+		keys, err := loadFile(cfg.ConfigFile, cfg)
+		if err != nil {
+			return nil, err
+		}
+		_, prefixSet = keys["base_url"]
+	}
 
-	err := env.Parse(cfg)
+	err = env.Parse(cfg)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	clearEmptyStoragePath(cfg, os.LookupEnv)
+	if _, ok := os.LookupEnv("BASE_URL"); ok {
+		prefixSet = true
+	}
+
+	for name, value := range explicit {
+		if err := defaults.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := explicit["b"]; ok {
+		prefixSet = true
+	}
+
+	if cfg.EnableHTTPS && !prefixSet {
+		// префикс по умолчанию должен вести на тот же сервер, но уже по https
+		cfg.PrefixURL = "https://" + strings.TrimPrefix(cfg.PrefixURL, "http://")
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// StorageBackend возвращает хранилище ссылок. Без явного STORAGE выбирается postgres,
+// если задан DATABASE_DSN, затем файл, если задан FILE_STORAGE_PATH, иначе память.
+func (c *Config) StorageBackend() string {
+	switch {
+	case c.Storage != "":
+		return c.Storage
+	case c.DatabaseDSN != "":
+		return StoragePostgres
+	case c.FileStoragePath != "":
+		return StorageFile
+	default:
+		return StorageMemory
 	}
+}
 
-	return cfg, nil
+// clearEmptyStoragePath применяет явно пустой FILE_STORAGE_PATH: env пропускает
+// пустые значения, а для пути это выбор хранения в памяти
+func clearEmptyStoragePath(cfg *Config, lookup func(string) (string, bool)) {
+	if value, ok := lookup("FILE_STORAGE_PATH"); ok && value == "" {
+		cfg.FileStoragePath = ""
+	}
 }
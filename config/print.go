@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "xxxxx"
+
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// Redacted возвращает копию настроек со скрытыми секретами
+func (c Config) Redacted() Config {
+	if c.SecretKey != "" {
+		c.SecretKey = redacted
+	}
+	if c.CodeSalt != "" {
+		c.CodeSalt = redacted
+	}
+	c.DatabaseDSN = redactDSN(c.DatabaseDSN)
+	if c.APIKeys != "" {
+		c.APIKeys = redacted
+	}
+	return c
+}
+
+// Print выводит действующие настройки со скрытыми секретами в формате файла настроек (json или yaml)
+func (c Config) Print(w io.Writer, format string) error {
+	values := make(map[string]any)
+	v := reflect.ValueOf(c.Redacted())
+	for i, envName := range envNames() {
+		if envName == "CONFIG" {
+			continue
+		}
+		value := v.Field(i).Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		values[strings.ToLower(envName)] = value
+	}
+
+	switch format {
+	case "", "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	case "yaml", "yml":
+		return yaml.NewEncoder(w).Encode(values)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func redactDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return redacted
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
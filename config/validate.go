@@ -0,0 +1,202 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/filelog"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/tlscert"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/tracing"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const (
+	StorageMemory   = "memory"
+	StorageFile     = "file"
+	StoragePostgres = "postgres"
+)
+
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+// Validate проверяет все поля и возвращает все найденные ошибки разом
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	check("SERVER_ADDRESS", validateAddr(c.Addr))
+	if c.GRPCAddr != "" {
+		check("GRPC_ADDRESS", validateAddr(c.GRPCAddr))
+	}
+	if c.AdminAddr != "" {
+		check("ADMIN_ADDRESS", validateAddr(c.AdminAddr))
+	}
+	check("BASE_URL", validatePrefixURL(c.PrefixURL))
+	if c.DatabaseDSN != "" {
+		_, err := pq.NewConnector(c.DatabaseDSN)
+		check("DATABASE_DSN", err)
+	}
+	switch c.StorageBackend() {
+	case StorageMemory:
+	case StorageFile:
+		if c.FileStoragePath == "" {
+			check("STORAGE", errors.New("file storage requires FILE_STORAGE_PATH"))
+		} else {
+			check("FILE_STORAGE_PATH", validateWritable(c.FileStoragePath))
+		}
+		policy, err := filelog.ParseSyncPolicy(c.FileSync)
+		check("FILE_STORAGE_SYNC", err)
+		if policy == filelog.SyncInterval && c.FileSyncInterval <= 0 {
+			check("FILE_STORAGE_SYNC_INTERVAL", errors.New("must be positive"))
+		}
+	case StoragePostgres:
+		if c.DatabaseDSN == "" {
+			check("STORAGE", errors.New("postgres storage requires DATABASE_DSN"))
+		}
+	default:
+		check("STORAGE", fmt.Errorf("unknown storage %q", c.Storage))
+	}
+
+	if !isKnownStrategy(c.CodeStrategy) {
+		check("SHORT_CODE_STRATEGY", fmt.Errorf("unknown strategy %q", c.CodeStrategy))
+	}
+	if c.CodeLength <= 0 || c.CodeLength > codegen.MaxLength {
+		check("SHORT_CODE_LENGTH", fmt.Errorf("must be between 1 and %d", codegen.MaxLength))
+	}
+	if len(c.CodeAlphabet) < 2 {
+		check("SHORT_CODE_ALPHABET", errors.New("must contain at least 2 characters"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		check("SHUTDOWN_TIMEOUT", errors.New("must be positive"))
+	}
+	if c.ReaperInterval < 0 {
+		check("REAPER_INTERVAL", errors.New("must not be negative"))
+	}
+	if c.CacheSize < 0 {
+		check("CACHE_SIZE", errors.New("must not be negative"))
+	}
+	if c.CacheSize > 0 && (c.CacheTTL <= 0 || c.CacheNegativeTTL <= 0) {
+		check("CACHE_TTL/CACHE_NEGATIVE_TTL", errors.New("must be positive when cache is enabled"))
+	}
+	if c.BatchMaxSize < 0 {
+		check("BATCH_MAX_SIZE", errors.New("must not be negative"))
+	}
+
+	switch c.RateLimitStore {
+	case "", RateLimitStoreMemory:
+	case RateLimitStorePostgres:
+		if c.DatabaseDSN == "" {
+			check("RATE_LIMIT_STORE", errors.New("postgres store requires DATABASE_DSN"))
+		}
+	default:
+		check("RATE_LIMIT_STORE", fmt.Errorf("unknown store %q", c.RateLimitStore))
+	}
+	if c.WriteRateLimit < 0 || (c.WriteRateLimit > 0 && c.WriteRateBurst <= 0) {
+		check("RATE_LIMIT_WRITE_RPS/RATE_LIMIT_WRITE_BURST", errors.New("rate must not be negative and burst must be positive"))
+	}
+	if c.RedirectRateLimit < 0 || (c.RedirectRateLimit > 0 && c.RedirectRateBurst <= 0) {
+		check("RATE_LIMIT_REDIRECT_RPS/RATE_LIMIT_REDIRECT_BURST", errors.New("rate must not be negative and burst must be positive"))
+	}
+
+	_, err := zapcore.ParseLevel(c.LogLevel)
+	check("LOG_LEVEL", err)
+	if c.LogFormat != "" && c.LogFormat != logger.FormatJSON && c.LogFormat != logger.FormatConsole {
+		check("LOG_FORMAT", fmt.Errorf("unknown format %q", c.LogFormat))
+	}
+
+	switch c.TraceExporter {
+	case "", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		check("TRACE_EXPORTER", fmt.Errorf("unknown exporter %q", c.TraceExporter))
+	}
+
+	_, err = clientip.ParseSubnet(c.TrustedSubnet)
+	check("TRUSTED_SUBNET", err)
+	_, err = clientip.ParseNetworks(c.TrustedProxies)
+	check("TRUSTED_PROXIES", err)
+
+	if c.EnableHTTPS {
+		_, err = tlscert.Config(c.TLSMinVersion, c.TLSCipherSuites)
+		check("TLS_MIN_VERSION/TLS_CIPHER_SUITES", err)
+		if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+			check("TLS_CERT_FILE/TLS_KEY_FILE", errors.New("must be set together"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+func validatePrefixURL(prefix string) error {
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("host is required")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("query and fragment are not allowed")
+	}
+	return nil
+}
+
+// validateWritable проверяет, что в файл хранилища можно писать, не изменяя его
+func validateWritable(path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func isKnownStrategy(strategy string) bool {
+	switch strategy {
+	case "", codegen.StrategyRandom, codegen.StrategySequence, codegen.StrategyHash, codegen.StrategySqids:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const CookieName = "user_id"
+
+type ctxKey struct{}
+
+// Authenticator выдаёт и проверяет подписанную HMAC куку с идентификатором пользователя
+type Authenticator struct {
+	secret []byte
+}
+
+func NewAuthenticator(secretKey string) *Authenticator {
+	secret := []byte(secretKey)
+	if len(secret) == 0 {
+		// без ключа куки не переживут рестарт, но подделать их всё равно нельзя
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return &Authenticator{secret: secret}
+}
+
+// Issue кладёт в контекст идентификатор пользователя из куки,
+// а если куки нет или подпись неверна - создаёт нового пользователя и выставляет куку
+func (a *Authenticator) Issue(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := a.UserIDFromRequest(r)
+		if !ok {
+			userID = NewUserID()
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    a.Sign(userID),
+				Path:     "/",
+				HttpOnly: true,
+			})
+		}
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), userID)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Require пропускает только запросы с валидной кукой, остальным отвечает 401
+func (a *Authenticator) Require(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := a.UserIDFromRequest(r)
+		if !ok {
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "user cookie is missing or invalid")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), userID)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Sign возвращает значение куки в формате <userID>.<hex(hmac-sha256(userID))>
+func (a *Authenticator) Sign(userID string) string {
+	return userID + "." + hex.EncodeToString(a.mac(userID))
+}
+
+// Verify проверяет подпись значения куки и возвращает идентификатор пользователя
+func (a *Authenticator) Verify(value string) (string, bool) {
+	userID, sign, found := strings.Cut(value, ".")
+	if !found || userID == "" {
+		return "", false
+	}
+	got, err := hex.DecodeString(sign)
+	if err != nil {
+		return "", false
+	}
+	if !hmac.Equal(got, a.mac(userID)) {
+		return "", false
+	}
+	return userID, true
+}
+
+// UserIDFromRequest возвращает пользователя из куки запроса, если её подпись верна
+func (a *Authenticator) UserIDFromRequest(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", false
+	}
+	return a.Verify(cookie.Value)
+}
+
+func (a *Authenticator) mac(userID string) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(userID))
+	return h.Sum(nil)
+}
+
+func NewUserID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withUser кладёт пользователя в контекст и в поля лога запроса
+func withUser(ctx context.Context, userID string) context.Context {
+	return logger.With(WithUserID(ctx, userID), zap.String("user_id", userID))
+}
+
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, userID)
+}
+
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(ctxKey{}).(string)
+	return userID, ok && userID != ""
+}
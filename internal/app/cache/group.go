@@ -0,0 +1,53 @@
+package cache
+
+import "sync"
+
+// Group схлопывает одновременные загрузки одного ключа в один вызов:
+// пока первый вызов не завершился, остальные ждут и получают его результат
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do выполняет load для key, если такая загрузка ещё не идёт. shared сообщает,
+// что результат получен от чужого вызова.
+func (g *Group[K, V]) Do(key K, load func() (V, error)) (value V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.value, c.err = load()
+	return c.value, c.err, false
+}
+
+// Forget отвязывает текущую загрузку key: следующий вызов Do начнёт новую,
+// а не получит результат, прочитанный до инвалидации
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
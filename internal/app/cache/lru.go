@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU - ограниченный по размеру кеш с вытеснением давно не читавшихся записей.
+// У каждой записи свой срок жизни, истёкшие записи не отдаются и удаляются при чтении.
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	items   map[K]*list.Element
+	order   *list.List
+	onEvict func()
+	now     func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// NewLRU создаёт кеш на size записей. onEvict, если задан, вызывается при вытеснении по размеру.
+func NewLRU[K comparable, V any](size int, onEvict func()) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    size,
+		items:   make(map[K]*list.Element, size),
+		order:   list.New(),
+		onEvict: onEvict,
+		now:     time.Now,
+	}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	e := elem.Value.(*entry[K, V])
+	if !c.now().Before(e.expires) {
+		c.removeElement(elem)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		if c.onEvict != nil {
+			c.onEvict()
+		}
+	}
+}
+
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Purge очищает кеш целиком
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[K]*list.Element, c.size)
+	c.order.Init()
+}
+
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry[K, V]).key)
+}
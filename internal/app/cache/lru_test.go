@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_evictsLeastRecentlyUsed(t *testing.T) {
+	evicted := 0
+	c := NewLRU[string, int](2, func() { evicted++ })
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	c.Get("a")
+	c.Set("c", 3, time.Minute)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, 1, evicted)
+}
+
+func TestLRU_expires(t *testing.T) {
+	now := time.Now()
+	c := NewLRU[string, int](10, nil)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestGroup_coalescesConcurrentLoads(t *testing.T) {
+	var g Group[string, int]
+	var loads atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = g.Do("key", func() (int, error) {
+				loads.Add(1)
+				<-release
+				return 42, nil
+			})
+		}(i)
+	}
+	// даём горутинам дойти до ожидания первой загрузки
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads.Load())
+	for _, result := range results {
+		assert.Equal(t, 42, result)
+	}
+}
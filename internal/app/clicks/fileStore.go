@@ -0,0 +1,139 @@
+package clicks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const dayLayout = "2006-01-02"
+
+type linkCounter struct {
+	total    int64
+	visitors map[string]struct{}
+	days     map[string]int64
+}
+
+// FileStore держит агрегаты переходов в памяти и дописывает сырые события в файл,
+// из которого агрегаты восстанавливаются при старте. Пустой путь - только память.
+type FileStore struct {
+	mu       sync.RWMutex
+	counters map[string]*linkCounter
+	path     string
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		counters: make(map[string]*linkCounter),
+		path:     path,
+	}
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) SaveClicks(_ context.Context, clicks []models.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path != "" {
+		var content []byte
+		for _, click := range clicks {
+			record, _ := json.Marshal(click)
+			content = append(content, record...)
+			content = append(content, '\n')
+		}
+
+		f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = f.Write(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, click := range clicks {
+		s.count(click)
+	}
+	return nil
+}
+
+func (s *FileStore) LinkStats(_ context.Context, shortURL string) (*models.LinkStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &models.LinkStats{ShortURL: shortURL, Days: []models.DayClicks{}}
+	counter, ok := s.counters[shortURL]
+	if !ok {
+		return stats, nil
+	}
+
+	stats.TotalClicks = counter.total
+	stats.UniqueVisitors = int64(len(counter.visitors))
+	for day, clicks := range counter.days {
+		stats.Days = append(stats.Days, models.DayClicks{Date: day, Clicks: clicks})
+	}
+	sort.Slice(stats.Days, func(i, j int) bool {
+		return stats.Days[i].Date < stats.Days[j].Date
+	})
+	return stats, nil
+}
+
+func (s *FileStore) count(click models.Click) {
+	counter, ok := s.counters[click.ShortURL]
+	if !ok {
+		counter = &linkCounter{
+			visitors: make(map[string]struct{}),
+			days:     make(map[string]int64),
+		}
+		s.counters[click.ShortURL] = counter
+	}
+	counter.total++
+	counter.visitors[click.Visitor()] = struct{}{}
+	counter.days[click.Time.UTC().Format(dayLayout)]++
+}
+
+func (s *FileStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		logger.Log.Error("error to read file", zap.String("file path", s.path), zap.String("err", err.Error()))
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var click models.Click
+		err = dec.Decode(&click)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			logger.Log.Error("error to parse json", zap.String("err", err.Error()))
+			return err
+		}
+		s.count(click)
+	}
+}
@@ -0,0 +1,124 @@
+package clicks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const (
+	queueSize     = 4096
+	batchSize     = 256
+	flushInterval = time.Second
+	flushTimeout  = 10 * time.Second
+)
+
+type Store interface {
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+	LinkStats(ctx context.Context, shortURL string) (*models.LinkStats, error)
+}
+
+// Recorder принимает события переходов без блокировки редиректа
+// и пачками сбрасывает их в хранилище в фоне
+type Recorder struct {
+	store   Store
+	events  chan models.Click
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewRecorder(store Store) *Recorder {
+	r := &Recorder{
+		store:  store,
+		events: make(chan models.Click, queueSize),
+	}
+
+	r.wg.Add(1)
+	go r.flush()
+
+	return r
+}
+
+// Record ставит событие в очередь. Если очередь переполнена, событие отбрасывается.
+func (r *Recorder) Record(click models.Click) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.events <- click:
+	default:
+		if dropped := r.dropped.Add(1); dropped%queueSize == 1 {
+			logger.Log.Warn("click queue is full, events dropped", zap.Int64("dropped", dropped))
+		}
+	}
+}
+
+func (r *Recorder) LinkStats(ctx context.Context, shortURL string) (*models.LinkStats, error) {
+	return r.store.LinkStats(ctx, shortURL)
+}
+
+// Close перестаёт принимать события и сбрасывает накопленные в хранилище
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.events)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *Recorder) flush() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, batchSize)
+	for {
+		select {
+		case click, ok := <-r.events:
+			if !ok {
+				r.save(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		r.save(batch)
+		batch = make([]models.Click, 0, batchSize)
+	}
+}
+
+func (r *Recorder) save(batch []models.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	err := r.store.SaveClicks(ctx, batch)
+	if err != nil {
+		logger.Log.Error("error to save clicks", zap.Int("count", len(batch)), zap.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,166 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+type ctxKey struct{}
+
+// Resolver определяет адрес клиента. Заголовкам X-Real-IP и X-Forwarded-For
+// доверяем только если непосредственный собеседник - один из доверенных прокси.
+type Resolver struct {
+	proxies []*net.IPNet
+}
+
+func NewResolver(trustedProxies string) (*Resolver, error) {
+	proxies, err := ParseNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{proxies: proxies}, nil
+}
+
+// Middleware кладёт адрес клиента в контекст запроса
+func (res *Resolver) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ip := res.ClientIP(r)
+		if ip != nil {
+			ctx := logger.With(WithIP(r.Context(), ip), zap.String("remote_ip", ip.String()))
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (res *Resolver) ClientIP(r *http.Request) net.IP {
+	return res.Resolve(r.RemoteAddr, r.Header.Get("X-Real-IP"), r.Header.Get("X-Forwarded-For"))
+}
+
+// Resolve определяет адрес клиента по адресу собеседника и значениям заголовков прокси
+func (res *Resolver) Resolve(remoteAddr string, realIP string, forwardedFor string) net.IP {
+	peer := parseIP(remoteAddr)
+	if peer == nil || !res.trusted(peer) {
+		return peer
+	}
+
+	if ip := parseIP(realIP); ip != nil {
+		return ip
+	}
+
+	// идём справа налево: правые адреса дописаны нашими прокси, первый недоверенный - клиент
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseIP(hops[i])
+		if ip == nil {
+			break
+		}
+		if !res.trusted(ip) {
+			return ip
+		}
+		peer = ip
+	}
+	return peer
+}
+
+func (res *Resolver) trusted(ip net.IP) bool {
+	for _, proxy := range res.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromContext возвращает адрес клиента, определённый Middleware
+func FromContext(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(ctxKey{}).(net.IP)
+	return ip, ok
+}
+
+func WithIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ip)
+}
+
+// Subnet - доверенная подсеть для служебных ручек. Пустая подсеть закрывает доступ для всех.
+type Subnet struct {
+	network *net.IPNet
+}
+
+func ParseSubnet(subnet string) (*Subnet, error) {
+	if strings.TrimSpace(subnet) == "" {
+		return &Subnet{}, nil
+	}
+	_, network, err := net.ParseCIDR(strings.TrimSpace(subnet))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted subnet %q: %w", subnet, err)
+	}
+	return &Subnet{network: network}, nil
+}
+
+func (s *Subnet) Allows(ip net.IP) bool {
+	return s.network != nil && ip != nil && s.network.Contains(ip)
+}
+
+// Middleware пропускает только клиентов из подсети, остальным отвечает 403
+func (s *Subnet) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ip, ok := FromContext(r.Context())
+		if !ok {
+			ip = parseIP(r.RemoteAddr)
+		}
+		if !s.Allows(ip) {
+			problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "client address is not in the trusted subnet")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// ParseNetworks разбирает список подсетей или одиночных адресов через запятую
+func ParseNetworks(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", item, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseIP принимает адрес как с портом, так и без
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
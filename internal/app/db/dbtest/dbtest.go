@@ -0,0 +1,69 @@
+// Package dbtest поднимает для тестов изолированную схему в postgres из TEST_DATABASE_DSN.
+// Без переменной окружения тесты, которым нужна база, пропускаются.
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+const dsnEnv = "TEST_DATABASE_DSN"
+
+// Open создаёт пустую схему, возвращает пул, который работает только в ней,
+// и удаляет схему по завершении теста
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skip(dsnEnv + " is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		admin, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+	})
+	return db
+}
+
+// withSearchPath добавляет к dsn в любом из форматов lib/pq параметр search_path
+func withSearchPath(dsn string, schema string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			query := u.Query()
+			query.Set("search_path", schema)
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
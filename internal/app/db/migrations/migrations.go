@@ -0,0 +1,308 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// ключ advisory lock, под которым мигрирует только один инстанс сервиса
+const lockKey = 4_815_162_342
+
+// версия, которой соответствует исходная таблица url, созданная до появления миграций
+const adoptedVersion = 1
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(sqlFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up применяет все ещё не применённые миграции по порядку
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err = apply(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name,
+			)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			logger.Log.Info("migration applied", zap.Int("version", migration.Version), zap.String("name", migration.Name))
+		}
+		return nil
+	})
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			err = apply(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`,
+				migration.Version,
+			)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			logger.Log.Info("migration reverted", zap.Int("version", migration.Version), zap.String("name", migration.Name))
+			return nil
+		}
+		return nil
+	})
+}
+
+// Status возвращает все известные миграции с временем применения, если оно было.
+// Только читает базу: таблица версий не создаётся, а старая схема не принимается как версия 1.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	if exists {
+		applied, err = appliedVersions(ctx, m.db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return statuses(m.migrations, applied), nil
+}
+
+func statuses(migrations []Migration, applied map[int]time.Time) []Status {
+	result := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// locked выполняет fn на выделенном соединении под advisory lock,
+// предварительно создав таблицу версий и приняв старую схему как версию 1
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey)
+	if err != nil {
+		return fmt.Errorf("unable to acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+		if err != nil {
+			logger.Log.Error("error to release migration lock", zap.String("err", err.Error()))
+		}
+	}()
+
+	err = prepare(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func prepare(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+		version    integer primary key,
+		name       varchar(255) NOT NULL,
+		applied_at timestamptz  NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("unable to create schema_migrations: %w", err)
+	}
+
+	var versions int
+	err = conn.QueryRowContext(ctx, `SELECT count(*) FROM schema_migrations`).Scan(&versions)
+	if err != nil {
+		return err
+	}
+	if versions > 0 {
+		return nil
+	}
+
+	var urlTableExists bool
+	err = conn.QueryRowContext(ctx, `SELECT to_regclass('url') IS NOT NULL`).Scan(&urlTableExists)
+	if err != nil {
+		return err
+	}
+	if !urlTableExists {
+		return nil
+	}
+
+	_, err = conn.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		adoptedVersion, "adopted",
+	)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("existing schema adopted", zap.Int("version", adoptedVersion))
+	return nil
+}
+
+// queryer - общее у *sql.DB и *sql.Conn
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, conn queryer) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// apply выполняет скрипт миграции и запись о версии в одной транзакции
+func apply(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// load читает пары файлов <version>_<name>.up.sql / <version>_<name>.down.sql
+func load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		version, name, direction, err := parseFileName(path.Base(file))
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if ok && migration.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, migration.Name, name)
+		}
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have up and down scripts", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFileName разбирает имя <version>_<name>.<up|down>.sql
+func parseFileName(base string) (version int, name string, direction string, err error) {
+	name, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+	if !ok || (direction != "up" && direction != "down") {
+		return 0, "", "", fmt.Errorf("unexpected migration file name %q", base)
+	}
+	rawVersion, name, ok := strings.Cut(name, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("unexpected migration file name %q", base)
+	}
+	version, err = strconv.Atoi(rawVersion)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("unexpected migration version in %q", base)
+	}
+	return version, name, direction, nil
+}
@@ -0,0 +1,182 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/dbtest"
+)
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		file      string
+		version   int
+		name      string
+		direction string
+		wantErr   bool
+	}{
+		{file: "0001_create_url.up.sql", version: 1, name: "create_url", direction: "up"},
+		{file: "0012_add_index.down.sql", version: 12, name: "add_index", direction: "down"},
+		{file: "0001_create_url.sql", wantErr: true},
+		{file: "0001_create_url.sideways.sql", wantErr: true},
+		{file: "0001.up.sql", wantErr: true},
+		{file: "0001_.up.sql", wantErr: true},
+		{file: "first_create_url.up.sql", wantErr: true},
+		{file: "0000_zero.up.sql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			version, name, direction, err := parseFileName(tt.file)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, version)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.direction, direction)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_tenth.up.sql":    {Data: []byte("up 10")},
+		"sql/0010_tenth.down.sql":  {Data: []byte("down 10")},
+		"sql/0002_second.up.sql":   {Data: []byte("up 2")},
+		"sql/0002_second.down.sql": {Data: []byte("down 2")},
+		"sql/0001_first.up.sql":    {Data: []byte("up 1")},
+		"sql/0001_first.down.sql":  {Data: []byte("down 1")},
+	}
+
+	migrations, err := load(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+		{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+		{Version: 10, Name: "tenth", Up: "up 10", Down: "down 10"},
+	}, migrations)
+}
+
+func TestLoad_errors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"sql/0001_first.up.sql": {Data: []byte("up")},
+		},
+		"bad name": {
+			"sql/first.up.sql":   {Data: []byte("up")},
+			"sql/first.down.sql": {Data: []byte("down")},
+		},
+		"same version with different names": {
+			"sql/0001_first.up.sql":   {Data: []byte("up")},
+			"sql/0001_other.down.sql": {Data: []byte("down")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad_embedded(t *testing.T) {
+	migrations, err := load(sqlFiles)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	// версии идут подряд с первой: пропуск обычно означает потерянный файл
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version, migration.Name)
+	}
+	assert.Equal(t, adoptedVersion, migrations[0].Version)
+}
+
+func TestStatuses(t *testing.T) {
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := statuses(
+		[]Migration{{Version: 1, Name: "first"}, {Version: 2, Name: "second"}},
+		map[int]time.Time{1: appliedAt},
+	)
+	require.Len(t, result, 2)
+	require.NotNil(t, result[0].AppliedAt)
+	assert.Equal(t, appliedAt, *result[0].AppliedAt)
+	assert.Nil(t, result[1].AppliedAt)
+}
+
+func TestMigrator_adoptAndStatus(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	// таблица из времён до миграций
+	_, err := db.Exec(`CREATE TABLE url
+	(
+		id           serial primary key,
+		short_url    varchar(450) NOT NULL,
+		original_url varchar(450) NOT NULL UNIQUE
+	)`)
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.Nil(t, status.AppliedAt)
+	}
+	var exists bool
+	require.NoError(t, db.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists))
+	assert.False(t, exists, "status must not create the version table")
+
+	require.NoError(t, migrator.Up(ctx))
+
+	var name string
+	require.NoError(t, db.QueryRow(`SELECT name FROM schema_migrations WHERE version = $1`, adoptedVersion).Scan(&name))
+	assert.Equal(t, "adopted", name)
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, status.Name)
+	}
+}
+
+func TestMigrator_duplicateShortURLs(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	all, err := load(sqlFiles)
+	require.NoError(t, err)
+	// схема до уникального индекса на short_url
+	before := &Migrator{db: db, migrations: all[:3]}
+	require.NoError(t, before.Up(ctx))
+
+	_, err = db.Exec(`INSERT INTO url (short_url, original_url) VALUES
+		('abcde', 'https://first.example.com'),
+		('abcde', 'https://second.example.com'),
+		('fghij', 'https://third.example.com')`)
+	require.NoError(t, err)
+
+	migrator := &Migrator{db: db, migrations: all}
+	require.NoError(t, migrator.Up(ctx))
+
+	codes := make(map[string]string)
+	rows, err := db.Query(`SELECT original_url, short_url FROM url`)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var original, short string
+		require.NoError(t, rows.Scan(&original, &short))
+		codes[original] = short
+	}
+	require.NoError(t, rows.Err())
+
+	assert.Equal(t, "abcde", codes["https://first.example.com"])
+	assert.NotEqual(t, "abcde", codes["https://second.example.com"])
+	assert.Equal(t, "fghij", codes["https://third.example.com"])
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS url
+(
+    id           serial primary key,
+    short_url    varchar(450) NOT NULL,
+    original_url varchar(450) NOT NULL UNIQUE
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS url_user_id_idx;
+ALTER TABLE url DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN IF NOT EXISTS is_deleted boolean NOT NULL DEFAULT false;
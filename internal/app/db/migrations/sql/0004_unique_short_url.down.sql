@@ -0,0 +1 @@
+DROP INDEX IF EXISTS url_short_url_key;
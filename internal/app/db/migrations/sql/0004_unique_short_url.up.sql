@@ -0,0 +1,9 @@
+-- старый генератор мог выдать один код нескольким ссылкам: код остаётся у первой из них,
+-- остальные получают новый, уникальный за счёт id (в старых кодах нет '-')
+UPDATE url
+SET short_url = url.short_url || '-' || url.id
+FROM (SELECT id, row_number() OVER (PARTITION BY short_url ORDER BY id) AS n FROM url) ranked
+WHERE ranked.id = url.id
+  AND ranked.n > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS url_short_url_key ON url (short_url);
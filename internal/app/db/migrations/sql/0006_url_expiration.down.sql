@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS url_archive;
+DROP INDEX IF EXISTS url_expires_at_idx;
+ALTER TABLE url DROP COLUMN IF EXISTS expires_at;
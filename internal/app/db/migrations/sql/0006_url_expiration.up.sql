@@ -0,0 +1,13 @@
+ALTER TABLE url ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+CREATE INDEX IF NOT EXISTS url_expires_at_idx ON url (expires_at) WHERE expires_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS url_archive
+(
+    id           integer      NOT NULL,
+    short_url    varchar(450) NOT NULL,
+    original_url varchar(450) NOT NULL,
+    user_id      varchar(64),
+    is_deleted   boolean      NOT NULL,
+    expires_at   timestamptz,
+    archived_at  timestamptz  NOT NULL DEFAULT now()
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS clicks
+(
+    id         bigserial primary key,
+    short_url  varchar(450) NOT NULL,
+    clicked_at timestamptz  NOT NULL,
+    referrer   text         NOT NULL DEFAULT '',
+    user_agent text         NOT NULL DEFAULT '',
+    ip         varchar(64)  NOT NULL DEFAULT '',
+    visitor    varchar(64)  NOT NULL
+);
+CREATE INDEX IF NOT EXISTS clicks_short_url_clicked_at_idx ON clicks (short_url, clicked_at);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS rate_limit_bucket
+(
+    key        varchar(512) primary key,
+    tokens     double precision NOT NULL,
+    updated_at timestamptz      NOT NULL
+);
+CREATE INDEX IF NOT EXISTS rate_limit_bucket_updated_at_idx ON rate_limit_bucket (updated_at);
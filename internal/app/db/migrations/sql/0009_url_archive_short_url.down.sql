@@ -0,0 +1 @@
+DROP INDEX IF EXISTS url_archive_short_url_idx;
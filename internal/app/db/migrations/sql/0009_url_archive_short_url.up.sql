@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS url_archive_short_url_idx ON url_archive (short_url);
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+type ClickService interface {
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+	LinkStats(ctx context.Context, shortURL string) (*models.LinkStats, error)
+}
@@ -3,3 +3,5 @@ package errs
 import "fmt"
 
 var ErrOriginalURLAlreadyExist = fmt.Errorf("original URL Already Exist")
+var ErrShortURLAlreadyExist = fmt.Errorf("short URL Already Exist")
+var ErrURLNotFound = fmt.Errorf("URL not found")
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+const storagePostgres = "postgres"
+
+// instrumentedURLService замеряет длительность каждого метода URLService
+type instrumentedURLService struct {
+	next URLService
+}
+
+func NewInstrumentedURLService(next URLService) URLService {
+	return &instrumentedURLService{next: next}
+}
+
+func (s *instrumentedURLService) SaveURL(ctx context.Context, url models.URL) (shortURL string, err error) {
+	defer func(start time.Time) { observe("SaveURL", start, err) }(time.Now())
+	return s.next.SaveURL(ctx, url)
+}
+
+func (s *instrumentedURLService) SaveBatchURL(ctx context.Context, batchURL []models.URL) (results map[string]models.BatchResult, err error) {
+	defer func(start time.Time) { observe("SaveBatchURL", start, err) }(time.Now())
+	return s.next.SaveBatchURL(ctx, batchURL)
+}
+
+func (s *instrumentedURLService) GetURL(ctx context.Context, shortURL string) (url *models.URL, err error) {
+	defer func(start time.Time) { observe("GetURL", start, err) }(time.Now())
+	return s.next.GetURL(ctx, shortURL)
+}
+
+func (s *instrumentedURLService) GetURLsByUserID(ctx context.Context, userID string) (urls []models.URL, err error) {
+	defer func(start time.Time) { observe("GetURLsByUserID", start, err) }(time.Now())
+	return s.next.GetURLsByUserID(ctx, userID)
+}
+
+func (s *instrumentedURLService) DeleteURLs(ctx context.Context, userID string, shortURLs []string) (err error) {
+	defer func(start time.Time) { observe("DeleteURLs", start, err) }(time.Now())
+	return s.next.DeleteURLs(ctx, userID, shortURLs)
+}
+
+func (s *instrumentedURLService) PurgeExpired(ctx context.Context, now time.Time) (n int64, err error) {
+	defer func(start time.Time) { observe("PurgeExpired", start, err) }(time.Now())
+	return s.next.PurgeExpired(ctx, now)
+}
+
+func (s *instrumentedURLService) Stats(ctx context.Context) (stats *models.ServiceStats, err error) {
+	defer func(start time.Time) { observe("Stats", start, err) }(time.Now())
+	return s.next.Stats(ctx)
+}
+
+// observe не считает ошибками ожидаемые конфликты уникальности и ненайденные ссылки
+func observe(method string, start time.Time, err error) {
+	if errors.Is(err, errs.ErrOriginalURLAlreadyExist) ||
+		errors.Is(err, errs.ErrShortURLAlreadyExist) ||
+		errors.Is(err, errs.ErrURLNotFound) {
+		err = nil
+	}
+	metrics.ObserveStorage(storagePostgres, method, start, err)
+}
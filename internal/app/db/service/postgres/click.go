@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+type ClickService struct {
+	db *tracedDB
+}
+
+func NewClickService(db *sql.DB) *ClickService {
+	return &ClickService{db: newTracedDB(db)}
+}
+
+func (c *ClickService) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	const columns = 6
+	vals := make([]any, 0, len(clicks)*columns)
+	placeholders := make([]string, 0, len(clicks))
+	for index, click := range clicks {
+		placeholders = append(placeholders, fmt.Sprintf("($%d,$%d,$%d,$%d,$%d,$%d)",
+			index*columns+1,
+			index*columns+2,
+			index*columns+3,
+			index*columns+4,
+			index*columns+5,
+			index*columns+6))
+		vals = append(vals, click.ShortURL, click.Time, click.Referrer, click.UserAgent, click.IP, click.Visitor())
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip, visitor) VALUES %s",
+		strings.Join(placeholders, ","),
+	)
+
+	_, err := c.db.ExecContext(ctx, query, vals...)
+	if err != nil {
+		return fmt.Errorf("unable to insert clicks: %w", err)
+	}
+	return nil
+}
+
+func (c *ClickService) LinkStats(ctx context.Context, shortURL string) (*models.LinkStats, error) {
+	stats := &models.LinkStats{ShortURL: shortURL, Days: []models.DayClicks{}}
+
+	err := c.db.QueryRowContext(
+		ctx,
+		`SELECT count(*), count(DISTINCT visitor) FROM clicks WHERE short_url = $1`,
+		shortURL,
+	).Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		logger.Log.Error("error select request", zap.String("err", err.Error()))
+		return nil, err
+	}
+
+	rows, err := c.db.QueryContext(
+		ctx,
+		`SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, count(*)
+		FROM clicks WHERE short_url = $1
+		GROUP BY day ORDER BY day`,
+		shortURL,
+	)
+	if err != nil {
+		logger.Log.Error("error select request", zap.String("err", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day models.DayClicks
+		if err := rows.Scan(&day.Date, &day.Clicks); err != nil {
+			logger.Log.Error("error parse request from db", zap.String("err", err.Error()))
+			return nil, err
+		}
+		stats.Days = append(stats.Days, day)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/ratelimit"
+)
+
+const (
+	rateLimitSweepInterval = time.Minute
+	// ведро, не тронутое дольше этого, заведомо полное при любых разумных лимитах
+	rateLimitIdleTTL = time.Hour
+)
+
+// RateLimitService хранит вёдра лимитов в общей таблице, чтобы несколько экземпляров
+// сервиса расходовали один лимит на клиента
+type RateLimitService struct {
+	db *tracedDB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewRateLimitService(db *sql.DB) *RateLimitService {
+	return &RateLimitService{db: newTracedDB(db)}
+}
+
+// Take берёт токен под блокировкой строки ведра, так что параллельные запросы
+// всех экземпляров видят согласованное число токенов
+func (s *RateLimitService) Take(
+	ctx context.Context,
+	key string,
+	limit ratelimit.Limit,
+	now time.Time,
+) (ratelimit.Result, error) {
+	s.sweep(ctx, now)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO rate_limit_bucket (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING`,
+		key, float64(limit.Burst), now,
+	)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("unable to create bucket: %w", err)
+	}
+
+	var tokens float64
+	var updatedAt time.Time
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT tokens, updated_at FROM rate_limit_bucket WHERE key = $1 FOR UPDATE`,
+		key,
+	).Scan(&tokens, &updatedAt)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("unable to lock bucket: %w", err)
+	}
+
+	tokens, res := ratelimit.Take(tokens, updatedAt, limit, now)
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE rate_limit_bucket SET tokens = $2, updated_at = $3 WHERE key = $1`,
+		key, tokens, now,
+	)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("unable to update bucket: %w", err)
+	}
+
+	return res, tx.Commit()
+}
+
+// sweep раз в rateLimitSweepInterval удаляет давно не использованные вёдра
+func (s *RateLimitService) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	// ошибка очистки не должна влиять на сам запрос, попробуем в следующий раз
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM rate_limit_bucket WHERE updated_at < $1`, now.Add(-rateLimitIdleTTL))
+}
@@ -0,0 +1,20 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+type SequenceService struct {
+	db *tracedDB
+}
+
+func NewSequenceService(db *sql.DB) *SequenceService {
+	return &SequenceService{db: newTracedDB(db)}
+}
+
+func (s *SequenceService) Next(ctx context.Context) (uint64, error) {
+	var n uint64
+	err := s.db.QueryRowContext(ctx, `SELECT nextval('short_url_seq')`).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/tracing"
+)
+
+// tracedDB открывает спан на каждый SQL запрос. Спан запроса с результатом
+// закрывается сразу после получения ответа, без учёта чтения строк.
+type tracedDB struct {
+	*sql.DB
+}
+
+func newTracedDB(db *sql.DB) *tracedDB {
+	return &tracedDB{DB: db}
+}
+
+func (db *tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (db *tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (db *tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+// startQuery называет спан по SQL команде: SELECT, INSERT, WITH...
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+	return tracing.Start(ctx, "postgres "+operation,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	)
+}
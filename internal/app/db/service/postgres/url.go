@@ -3,69 +3,292 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/errs"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
 	"strings"
+	"time"
 
 	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
+const pgerrUniqueViolation = "23505"
+
+const (
+	shortURLConstraint    = "url_short_url_key"
+	originalURLConstraint = "url_original_url_key"
+)
+
+const selectURL = "SELECT id, short_url, original_url, coalesce(user_id, ''), is_deleted, expires_at FROM url"
+
+// selectArchivedURL находит вычищенную ссылку: её код остаётся занятым
+const selectArchivedURL = `SELECT id, short_url, original_url, coalesce(user_id, ''), is_deleted, expires_at
+	FROM url_archive WHERE short_url = $1 ORDER BY archived_at DESC LIMIT 1`
+
+// archiveURL переносит подходящие под условие строки из url в url_archive одним запросом
+const archiveURL = `WITH archived AS (
+		DELETE FROM url WHERE %s
+		RETURNING id, short_url, original_url, user_id, is_deleted, expires_at
+	)
+	INSERT INTO url_archive (id, short_url, original_url, user_id, is_deleted, expires_at)
+	SELECT id, short_url, original_url, user_id, is_deleted, expires_at FROM archived`
+
+// deadURLs выбирает удалённые и истёкшие по часам БД ссылки на адреса из $1
+const deadURLs = "original_url = ANY($1) AND (is_deleted OR expires_at <= now())"
+
 type URLService struct {
-	db *sql.DB
+	db *tracedDB
 }
 
 func NewURLService(db *sql.DB) (*URLService, error) {
-	err := createTable(context.Background(), db)
-	if err != nil {
-		return nil, err
-	}
-	return &URLService{db: db}, nil
+	return &URLService{db: newTracedDB(db)}, nil
 }
 
+// SaveURL сохраняет ссылку в одной транзакции с переносом в архив её мёртвой предшественницы
 func (u *URLService) SaveURL(ctx context.Context, url models.URL) (string, error) {
-	existedURL, err := u.getURLByQuery(ctx, "select * from url WHERE original_url = $1", url.OriginalURL)
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// удалённая или истёкшая ссылка не должна мешать сократить тот же адрес заново,
+	// как в файловом хранилище и хранилище в памяти: адрес получит новый код
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(archiveURL, deadURLs), pq.Array([]string{url.OriginalURL}))
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("unable to archive dead row: %w", err)
+	}
+
+	var existedShortURL string
+	err = tx.QueryRowContext(ctx, "SELECT short_url FROM url WHERE original_url = $1", url.OriginalURL).
+		Scan(&existedShortURL)
+	if err == nil {
+		return existedShortURL, errs.ErrOriginalURLAlreadyExist
 	}
-	if existedURL != nil {
-		return existedURL.ShortURL, errs.ErrOriginalURLAlreadyExist
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("unable to select existing row: %w", err)
 	}
 
-	query := `INSERT INTO url (short_url, original_url) VALUES ($1, $2)`
+	// код из архива не выдаётся повторно: опубликованная ссылка не должна вести на другой адрес
+	query := `INSERT INTO url (short_url, original_url, user_id, expires_at)
+		SELECT $1::varchar, $2::varchar, $3::varchar, $4::timestamptz
+		WHERE NOT EXISTS (SELECT 1 FROM url_archive WHERE short_url = $1)`
 
-	_, err = u.db.ExecContext(ctx, query, url.ShortURL, url.OriginalURL)
+	res, err := tx.ExecContext(ctx, query, url.ShortURL, url.OriginalURL, url.UserID, url.ExpiresAt)
+	if isUniqueViolation(err, shortURLConstraint) {
+		return "", errs.ErrShortURLAlreadyExist
+	}
+	if isUniqueViolation(err, originalURLConstraint) {
+		// ссылку успели сохранить параллельным запросом, а транзакция после ошибки уже не читает
+		tx.Rollback()
+		existedURL, err := u.getURLByQuery(ctx, selectURL+" WHERE original_url = $1", url.OriginalURL)
+		if err != nil {
+			return "", err
+		}
+		if existedURL != nil {
+			return existedURL.ShortURL, errs.ErrOriginalURLAlreadyExist
+		}
+		return "", fmt.Errorf("unable to insert row: conflicting original url disappeared")
+	}
 	if err != nil {
 		return "", fmt.Errorf("unable to insert row: %w", err)
 	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("unable to insert row: %w", err)
+	}
+	if inserted == 0 {
+		return "", errs.ErrShortURLAlreadyExist
+	}
 
+	err = tx.Commit()
+	if err != nil {
+		return "", fmt.Errorf("unable to commit transaction: %w", err)
+	}
 	return "", nil
 }
 
-func (u *URLService) SaveBatchURL(ctx context.Context, batchURL []models.URL) error {
+// SaveBatchURL сохраняет пачку в одной транзакции: при любой ошибке в БД не остаётся ни одной её ссылки
+func (u *URLService) SaveBatchURL(ctx context.Context, batchURL []models.URL) (map[string]models.BatchResult, error) {
+	originalURLs := make([]string, 0, len(batchURL))
 	var vals []any
 	var placeholders []string
 	for index, url := range batchURL {
-		placeholders = append(placeholders, fmt.Sprintf("($%d,$%d)",
-			index*2+1,
-			index*2+2))
-		vals = append(vals, url.ShortURL, url.OriginalURL)
+		placeholders = append(placeholders, fmt.Sprintf("($%d,$%d,$%d,$%d)",
+			index*4+1,
+			index*4+2,
+			index*4+3,
+			index*4+4))
+		vals = append(vals, url.ShortURL, url.OriginalURL, url.UserID, url.ExpiresAt)
+		originalURLs = append(originalURLs, url.OriginalURL)
 	}
 
-	query := fmt.Sprintf("INSERT INTO url (short_url, original_url) VALUES %s", strings.Join(placeholders, ","))
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	_, err := u.db.ExecContext(ctx, query, vals...)
+	// удалённые и истёкшие ссылки на те же адреса не должны мешать сократить их заново
+	_, err = tx.ExecContext(
+		ctx,
+		fmt.Sprintf(archiveURL, deadURLs),
+		pq.Array(originalURLs),
+	)
 	if err != nil {
-		return fmt.Errorf("unable to insert row: %w", err)
+		return nil, fmt.Errorf("unable to archive dead rows: %w", err)
 	}
 
-	return nil
+	shortURLs := make([]string, 0, len(batchURL))
+	for _, url := range batchURL {
+		shortURLs = append(shortURLs, url.ShortURL)
+	}
+	var archived bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM url_archive WHERE short_url = ANY($1))",
+		pq.Array(shortURLs),
+	).Scan(&archived)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check archived short urls: %w", err)
+	}
+	if archived {
+		return nil, errs.ErrShortURLAlreadyExist
+	}
 
+	query := fmt.Sprintf(
+		`INSERT INTO url (short_url, original_url, user_id, expires_at) VALUES %s
+		ON CONFLICT (original_url) DO NOTHING
+		RETURNING original_url, short_url`,
+		strings.Join(placeholders, ","),
+	)
+	rows, err := tx.QueryContext(ctx, query, vals...)
+	if isUniqueViolation(err, shortURLConstraint) {
+		return nil, errs.ErrShortURLAlreadyExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to insert row: %w", err)
+	}
+	results, err := scanResults(rows, models.BatchCreated)
+	if isUniqueViolation(err, shortURLConstraint) {
+		return nil, errs.ErrShortURLAlreadyExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to insert row: %w", err)
+	}
+
+	var existed []string
+	for _, originalURL := range originalURLs {
+		if _, ok := results[originalURL]; !ok {
+			existed = append(existed, originalURL)
+		}
+	}
+	if len(existed) > 0 {
+		rows, err = tx.QueryContext(
+			ctx,
+			"SELECT original_url, short_url FROM url WHERE original_url = ANY($1)",
+			pq.Array(existed),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to select existing rows: %w", err)
+		}
+		existedResults, err := scanResults(rows, models.BatchExisting)
+		if err != nil {
+			return nil, fmt.Errorf("unable to select existing rows: %w", err)
+		}
+		for originalURL, result := range existedResults {
+			results[originalURL] = result
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("unable to commit transaction: %w", err)
+	}
+	return results, nil
 }
 
+// GetURL ищет ссылку и в архиве: вычищенная ссылка отдаётся истёкшей или удалённой, как до переноса
 func (u *URLService) GetURL(ctx context.Context, shortURL string) (*models.URL, error) {
-	return u.getURLByQuery(ctx, "select * from url WHERE short_url = $1", shortURL)
+	url, err := u.getURLByQuery(ctx, selectURL+" WHERE short_url = $1", shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if url == nil {
+		url, err = u.getURLByQuery(ctx, selectArchivedURL, shortURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if url == nil {
+		return nil, errs.ErrURLNotFound
+	}
+	return url, nil
+}
+
+func (u *URLService) GetURLsByUserID(ctx context.Context, userID string) ([]models.URL, error) {
+	rows, err := u.db.QueryContext(
+		ctx,
+		selectURL+" WHERE user_id = $1 AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now()) ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		logger.Log.Error("error select request", zap.String("err", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []models.URL
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			logger.Log.Error("error parse request from db", zap.String("err", err.Error()))
+			return nil, err
+		}
+		urls = append(urls, *url)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (u *URLService) DeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
+	query := `UPDATE url SET is_deleted = true WHERE user_id = $1 AND short_url = ANY($2)`
+
+	_, err := u.db.ExecContext(ctx, query, userID, pq.Array(shortURLs))
+	if err != nil {
+		return fmt.Errorf("unable to delete rows: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeExpired переносит в архив все ссылки, истёкшие к моменту now
+func (u *URLService) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := u.db.ExecContext(ctx, fmt.Sprintf(archiveURL, "expires_at <= $1"), now)
+	if err != nil {
+		return 0, fmt.Errorf("unable to archive expired rows: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (u *URLService) Stats(ctx context.Context) (*models.ServiceStats, error) {
+	var stats models.ServiceStats
+	err := u.db.QueryRowContext(
+		ctx,
+		`SELECT count(*), count(DISTINCT user_id) FROM url
+		WHERE NOT is_deleted AND (expires_at IS NULL OR expires_at > now())`,
+	).Scan(&stats.URLs, &stats.Users)
+	if err != nil {
+		logger.Log.Error("error select request", zap.String("err", err.Error()))
+		return nil, err
+	}
+	return &stats, nil
 }
 
 func (u *URLService) getURLByQuery(ctx context.Context, query string, args ...any) (*models.URL, error) {
@@ -85,8 +308,8 @@ func (u *URLService) getURLByQuery(ctx context.Context, query string, args ...an
 		return nil, nil
 	}
 
-	var url models.URL
-	if err := rows.Scan(&url.ID, &url.ShortURL, &url.OriginalURL); err != nil {
+	url, err := scanURL(rows)
+	if err != nil {
 		logger.Log.Error("error parse request from db", zap.String("err", err.Error()))
 		return nil, err
 	}
@@ -94,17 +317,42 @@ func (u *URLService) getURLByQuery(ctx context.Context, query string, args ...an
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-	return &url, nil
+	return url, nil
 }
 
-func createTable(ctx context.Context, db *sql.DB) error {
-	query := `CREATE TABLE IF NOT EXISTS url
-	(
-		id           serial primary key,
-		short_url    varchar(450) NOT NULL,
-		original_url varchar(450) NOT NULL UNIQUE
-	)`
+// scanResults читает пары original_url, short_url со статусом status и закрывает rows
+func scanResults(rows *sql.Rows, status models.BatchStatus) (map[string]models.BatchResult, error) {
+	defer rows.Close()
 
-	_, err := db.ExecContext(ctx, query)
-	return err
+	results := make(map[string]models.BatchResult)
+	for rows.Next() {
+		var originalURL, shortURL string
+		err := rows.Scan(&originalURL, &shortURL)
+		if err != nil {
+			return nil, err
+		}
+		results[originalURL] = models.BatchResult{ShortURL: shortURL, Status: status}
+	}
+	return results, rows.Err()
+}
+
+func scanURL(rows *sql.Rows) (*models.URL, error) {
+	var url models.URL
+	var expiresAt sql.NullTime
+	err := rows.Scan(&url.ID, &url.ShortURL, &url.OriginalURL, &url.UserID, &url.DeletedFlag, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+	return &url, nil
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pgerrUniqueViolation && pqErr.Constraint == constraint
 }
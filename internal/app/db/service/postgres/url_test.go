@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/dbtest"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/migrations"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+func newTestURLService(t *testing.T) *URLService {
+	db := dbtest.Open(t)
+	migrator, err := migrations.NewMigrator(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(context.Background()))
+
+	service, err := NewURLService(db)
+	require.NoError(t, err)
+	return service
+}
+
+func TestURLService_archivedCodeStaysTaken(t *testing.T) {
+	ctx := context.Background()
+	u := newTestURLService(t)
+
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err := u.SaveURL(ctx, models.URL{ShortURL: "abcde", OriginalURL: "https://ya.ru", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	purged, err := u.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	url, err := u.GetURL(ctx, "abcde")
+	require.NoError(t, err)
+	assert.True(t, url.Expired(time.Now()))
+
+	_, err = u.SaveURL(ctx, models.URL{ShortURL: "abcde", OriginalURL: "https://example.com"})
+	assert.ErrorIs(t, err, errs.ErrShortURLAlreadyExist)
+	_, err = u.SaveBatchURL(ctx, []models.URL{{ShortURL: "abcde", OriginalURL: "https://example.com"}})
+	assert.ErrorIs(t, err, errs.ErrShortURLAlreadyExist)
+}
+
+// Мёртвая по часам БД ссылка уходит в архив в той же транзакции, что и вставка нового кода
+func TestURLService_reshortenDead(t *testing.T) {
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		kill func(t *testing.T, u *URLService) models.URL
+	}{
+		{
+			name: "deleted",
+			kill: func(t *testing.T, u *URLService) models.URL {
+				url := models.URL{ShortURL: "abcde", OriginalURL: "https://ya.ru", UserID: "owner"}
+				_, err := u.SaveURL(ctx, url)
+				require.NoError(t, err)
+				require.NoError(t, u.DeleteURLs(ctx, "owner", []string{"abcde"}))
+				return url
+			},
+		},
+		{
+			name: "expired",
+			kill: func(t *testing.T, u *URLService) models.URL {
+				url := models.URL{ShortURL: "abcde", OriginalURL: "https://ya.ru", ExpiresAt: &expired}
+				_, err := u.SaveURL(ctx, url)
+				require.NoError(t, err)
+				return url
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := newTestURLService(t)
+			dead := test.kill(t, u)
+
+			// код мёртвой ссылки уже не выдаётся, а живая ссылка остаётся конфликтом
+			_, err := u.SaveURL(ctx, models.URL{ShortURL: dead.ShortURL, OriginalURL: dead.OriginalURL})
+			assert.ErrorIs(t, err, errs.ErrShortURLAlreadyExist)
+			_, err = u.SaveURL(ctx, models.URL{ShortURL: "fghij", OriginalURL: dead.OriginalURL})
+			require.NoError(t, err)
+			existed, err := u.SaveURL(ctx, models.URL{ShortURL: "klmno", OriginalURL: dead.OriginalURL})
+			assert.ErrorIs(t, err, errs.ErrOriginalURLAlreadyExist)
+			assert.Equal(t, "fghij", existed)
+
+			old, err := u.GetURL(ctx, dead.ShortURL)
+			require.NoError(t, err)
+			assert.True(t, old.DeletedFlag || old.Expired(time.Now()))
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/ratelimit"
+)
+
+type RateLimitService interface {
+	Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error)
+}
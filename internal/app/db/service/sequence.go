@@ -0,0 +1,7 @@
+package service
+
+import "context"
+
+type SequenceService interface {
+	Next(ctx context.Context) (uint64, error)
+}
@@ -6,8 +6,11 @@ import (
 )
 
 type Services struct {
-	PingService PingService
-	URLService  URLService
+	PingService      PingService
+	URLService       URLService
+	SequenceService  SequenceService
+	ClickService     ClickService
+	RateLimitService RateLimitService
 }
 
 func NewPostgresServices(db *sql.DB) (*Services, error) {
@@ -16,7 +19,10 @@ func NewPostgresServices(db *sql.DB) (*Services, error) {
 		return nil, err
 	}
 	return &Services{
-		PingService: postgres.NewPingService(db),
-		URLService:  urlService,
+		PingService:      postgres.NewPingService(db),
+		URLService:       NewInstrumentedURLService(urlService),
+		SequenceService:  postgres.NewSequenceService(db),
+		ClickService:     postgres.NewClickService(db),
+		RateLimitService: postgres.NewRateLimitService(db),
 	}, nil
 }
@@ -2,11 +2,20 @@ package service
 
 import (
 	"context"
+	"time"
+
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
 )
 
 type URLService interface {
 	SaveURL(ctx context.Context, url models.URL) (string, error)
-	SaveBatchURL(ctx context.Context, batchURL []models.URL) error
+	// SaveBatchURL возвращает результаты по исходным адресам пачки: BatchCreated
+	// для сохранённых и BatchExisting с существующим кодом для уже сокращённых
+	SaveBatchURL(ctx context.Context, batchURL []models.URL) (map[string]models.BatchResult, error)
+	// GetURL возвращает errs.ErrURLNotFound, если ссылки с таким кодом нет
 	GetURL(ctx context.Context, shortURL string) (*models.URL, error)
+	GetURLsByUserID(ctx context.Context, userID string) ([]models.URL, error)
+	DeleteURLs(ctx context.Context, userID string, shortURLs []string) error
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+	Stats(ctx context.Context) (*models.ServiceStats, error)
 }
@@ -0,0 +1,75 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
+)
+
+// UserTokenKey - ключ метаданных с подписанным идентификатором пользователя, аналог куки user_id
+const UserTokenKey = "user-token"
+
+// authInterceptor повторяет Authenticator.Issue, а для методов из required - Authenticator.Require
+func authInterceptor(authenticator *auth.Authenticator, required map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		userID, ok := authenticator.Verify(metadataValue(ctx, UserTokenKey))
+		if !ok {
+			if required[info.FullMethod] {
+				return nil, status.Error(codes.Unauthenticated, "invalid user token")
+			}
+			userID = auth.NewUserID()
+			err := grpc.SetHeader(ctx, metadata.Pairs(UserTokenKey, authenticator.Sign(userID)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return handler(auth.WithUserID(ctx, userID), req)
+	}
+}
+
+// clientIPInterceptor повторяет clientip.Resolver.Middleware, заголовки прокси берутся из метаданных
+func clientIPInterceptor(resolver *clientip.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var remoteAddr string
+		if p, ok := peer.FromContext(ctx); ok {
+			remoteAddr = p.Addr.String()
+		}
+		ip := resolver.Resolve(remoteAddr, metadataValue(ctx, "x-real-ip"), metadataValue(ctx, "x-forwarded-for"))
+		if ip != nil {
+			ctx = clientip.WithIP(ctx, ip)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// trustedSubnetInterceptor пускает к методам из methods только клиентов доверенной подсети
+func trustedSubnetInterceptor(subnet *clientip.Subnet, methods map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if methods[info.FullMethod] {
+			ip, _ := clientip.FromContext(ctx)
+			if !subnet.Allows(ip) {
+				return nil, status.Error(codes.PermissionDenied, "client is not in trusted subnet")
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,236 @@
+package grpcserver
+
+//go:generate buf generate ../../../api/proto --template ../../../api/buf.gen.yaml
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/grpcserver/pb"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+// Server реализует gRPC API поверх тех же хранилищ ссылок, что и HTTP обработчики
+type Server struct {
+	pb.UnimplementedShortenerServer
+
+	urlShortener URLShortener
+	urlRemover   URLRemover
+	prefixURL    string
+}
+
+func NewServer(urlShortener URLShortener, urlRemover URLRemover, prefixURL string) *Server {
+	return &Server{
+		urlShortener: urlShortener,
+		urlRemover:   urlRemover,
+		prefixURL:    prefixURL + "/",
+	}
+}
+
+// New собирает grpc.Server с перехватчиками, повторяющими HTTP middleware
+func New(
+	urlShortener URLShortener,
+	urlRemover URLRemover,
+	authenticator *auth.Authenticator,
+	cfg *config.Config,
+) (*grpc.Server, error) {
+	resolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	trustedSubnet, err := clientip.ParseSubnet(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		clientIPInterceptor(resolver),
+		authInterceptor(authenticator, map[string]bool{
+			pb.Shortener_ListUserURLs_FullMethodName:   true,
+			pb.Shortener_DeleteUserURLs_FullMethodName: true,
+		}),
+		trustedSubnetInterceptor(trustedSubnet, map[string]bool{
+			pb.Shortener_Stats_FullMethodName: true,
+		}),
+	))
+	pb.RegisterShortenerServer(srv, NewServer(urlShortener, urlRemover, cfg.PrefixURL))
+	return srv, nil
+}
+
+func (s *Server) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "url is required")
+	}
+	if err := shortener.ValidateOriginalURL(req.GetUrl()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var expiresAt *time.Time
+	if req.GetExpiresAt() != nil {
+		at := req.GetExpiresAt().AsTime()
+		expiresAt = &at
+	}
+	expiresAt, err := shortener.ExpiresAt(req.GetTtl(), expiresAt, time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	userID, _ := auth.UserIDFromContext(ctx)
+	shortURL, err := s.urlShortener.Add(ctx, models.URL{
+		ShortURL:    req.GetAlias(),
+		OriginalURL: req.GetUrl(),
+		UserID:      userID,
+		ExpiresAt:   expiresAt,
+	})
+	if errors.Is(err, errs.ErrConflictOriginalURL) {
+		metrics.LinkConflict()
+		return &pb.ShortenResponse{Result: s.prefixURL + shortURL, Existed: true}, nil
+	}
+	if err != nil {
+		return nil, shortenError(err)
+	}
+	metrics.LinkCreated()
+
+	return &pb.ShortenResponse{Result: s.prefixURL + shortURL}, nil
+}
+
+func (s *Server) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	userID, _ := auth.UserIDFromContext(ctx)
+	var urls []models.URL
+	for _, item := range req.GetItems() {
+		urls = append(urls, models.URL{
+			ShortURL:    item.GetAlias(),
+			OriginalURL: item.GetOriginalUrl(),
+			UserID:      userID,
+		})
+	}
+
+	results, err := s.urlShortener.AddBatch(ctx, urls)
+	if err != nil {
+		return nil, shortenError(err)
+	}
+
+	response := &pb.ShortenBatchResponse{}
+	for i, result := range results {
+		item := &pb.ShortenBatchResponse_Item{
+			CorrelationId: req.GetItems()[i].GetCorrelationId(),
+			Status:        string(result.Status),
+		}
+		switch result.Status {
+		case models.BatchCreated:
+			metrics.LinkCreated()
+			item.ShortUrl = s.prefixURL + result.ShortURL
+		case models.BatchExisting:
+			metrics.LinkConflict()
+			item.ShortUrl = s.prefixURL + result.ShortURL
+		case models.BatchInvalid:
+			item.Error = result.Err.Error()
+		}
+		response.Items = append(response.Items, item)
+	}
+	return response, nil
+}
+
+func (s *Server) Resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	url, err := s.urlShortener.Get(ctx, req.GetShortUrl())
+	if err == nil && url.OriginalURL == "" {
+		err = errs.ErrNotFound
+	}
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		metrics.NotFound()
+		return nil, status.Error(codes.NotFound, "short url not found")
+	case errors.Is(err, errs.ErrGone):
+		metrics.NotFound()
+		return nil, goneError()
+	case err != nil:
+		logger.FromContext(ctx).Error("error to get url", zap.String("err", err.Error()))
+		return nil, status.Error(codes.Unavailable, errs.ErrUnavailable.Error())
+	}
+	metrics.Redirect()
+	return &pb.ResolveResponse{OriginalUrl: url.OriginalURL}, nil
+}
+
+// goneError отличает удалённую или истёкшую ссылку от несуществующей, как 410 в HTTP API.
+// Клиент узнаёт её по FailedPrecondition и ErrorInfo с причиной gone.
+func goneError() error {
+	st := status.New(codes.FailedPrecondition, "short url is gone")
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: problem.CodeGone, Domain: "shortener.v1"})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func (s *Server) ListUserURLs(ctx context.Context, _ *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "user token required")
+	}
+
+	urls, err := s.urlShortener.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Error("error to get user urls", zap.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "unable to get user urls")
+	}
+
+	response := &pb.ListUserURLsResponse{}
+	for _, url := range urls {
+		response.Items = append(response.Items, &pb.ListUserURLsResponse_Item{
+			ShortUrl:    s.prefixURL + url.ShortURL,
+			OriginalUrl: url.OriginalURL,
+		})
+	}
+	return response, nil
+}
+
+func (s *Server) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "user token required")
+	}
+
+	err := s.urlRemover.Remove(userID, req.GetShortUrls())
+	if err != nil {
+		logger.FromContext(ctx).Warn("error to queue urls for deletion", zap.String("err", err.Error()))
+		return nil, status.Error(codes.Unavailable, "deletion queue is full, retry later")
+	}
+	return &pb.DeleteUserURLsResponse{}, nil
+}
+
+func (s *Server) Stats(ctx context.Context, _ *pb.StatsRequest) (*pb.StatsResponse, error) {
+	stats, err := s.urlShortener.Stats(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("error to get service stats", zap.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "unable to get service stats")
+	}
+	return &pb.StatsResponse{Urls: stats.URLs, Users: stats.Users}, nil
+}
+
+// shortenError переводит ошибки сохранения ссылки в статусы gRPC
+func shortenError(err error) error {
+	switch {
+	case errors.Is(err, errs.ErrAliasTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, errs.ErrInvalidAlias), errors.Is(err, errs.ErrReservedAlias), errors.Is(err, errs.ErrBatchTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		logger.Log.Error("error to create short url", zap.String("err", err.Error()))
+		return status.Error(codes.InvalidArgument, "unable to create short url")
+	}
+}
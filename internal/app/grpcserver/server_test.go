@@ -0,0 +1,122 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/grpcserver/pb"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+)
+
+func newTestClient(t *testing.T) pb.ShortenerClient {
+	mapper := shortener.NewFileURLMapper(
+		codegen.NewRandom(codegen.Base62Alphabet, 5),
+		filepath.Join(t.TempDir(), "short-url-db.json"),
+	)
+	remover := shortener.NewURLRemover(mapper)
+	t.Cleanup(remover.Close)
+
+	srv, err := New(mapper, remover, auth.NewAuthenticator("secret"), &config.Config{PrefixURL: "http://localhost:80"})
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewShortenerClient(conn)
+}
+
+func TestServer_ShortenAndResolve(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	var header metadata.MD
+	created, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://ya.ru"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.False(t, created.GetExisted())
+	require.NotEmpty(t, header.Get(UserTokenKey))
+
+	code := created.GetResult()[len("http://localhost:80/"):]
+	resolved, err := client.Resolve(ctx, &pb.ResolveRequest{ShortUrl: code})
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", resolved.GetOriginalUrl())
+
+	again, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://ya.ru"})
+	require.NoError(t, err)
+	assert.True(t, again.GetExisted())
+	assert.Equal(t, created.GetResult(), again.GetResult())
+
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{ShortUrl: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// удалённая ссылка отличается от несуществующей
+	userCtx := metadata.AppendToOutgoingContext(ctx, UserTokenKey, header.Get(UserTokenKey)[0])
+	_, err = client.DeleteUserURLs(userCtx, &pb.DeleteUserURLsRequest{ShortUrls: []string{code}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err = client.Resolve(ctx, &pb.ResolveRequest{ShortUrl: code})
+		return status.Code(err) != codes.OK
+	}, 3*time.Second, 10*time.Millisecond)
+	st := status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, "gone", info.GetReason())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com", Alias: "api"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "not a url"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_userURLs(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.ListUserURLs(context.Background(), &pb.ListUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var header metadata.MD
+	_, err = client.Shorten(context.Background(), &pb.ShortenRequest{Url: "https://ya.ru"}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserTokenKey, header.Get(UserTokenKey)[0])
+	list, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetItems(), 1)
+	assert.Equal(t, "https://ya.ru", list.GetItems()[0].GetOriginalUrl())
+}
+
+func TestServer_StatsRequiresTrustedSubnet(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.Stats(context.Background(), &pb.StatsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,19 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+type URLShortener interface {
+	Add(ctx context.Context, url models.URL) (string, error)
+	AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error)
+	Get(ctx context.Context, shortURL string) (models.URL, error)
+	GetByUserID(ctx context.Context, userID string) ([]models.URL, error)
+	Stats(ctx context.Context) (*models.ServiceStats, error)
+}
+
+type URLRemover interface {
+	Remove(userID string, shortURLs []string) error
+}
@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+type component struct {
+	name  string
+	serve func() error
+	stop  func(ctx context.Context) error
+}
+
+// Manager владеет компонентами приложения: запускает серверы и при завершении
+// останавливает всё в порядке, обратном регистрации, укладываясь в общий дедлайн.
+// Поэтому регистрировать нужно от нижнего слоя к верхнему: пул БД, фоновые воркеры, серверы.
+type Manager struct {
+	timeout time.Duration
+
+	mu         sync.Mutex
+	components []component
+	stopped    bool
+}
+
+func New(shutdownTimeout time.Duration) *Manager {
+	return &Manager{timeout: shutdownTimeout}
+}
+
+// Go регистрирует компонент с блокирующим serve, например http.Server.ListenAndServe.
+// serve должен вернуть nil после вызова stop.
+func (m *Manager) Go(name string, serve func() error, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, serve: serve, stop: stop})
+}
+
+// OnStop регистрирует уже запущенную фоновую работу, которую надо остановить при завершении
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.Go(name, nil, stop)
+}
+
+// Run запускает serve всех компонентов и ждёт отмены ctx или ошибки любого из них, после чего
+// вызывает Shutdown. Возвращает ошибку serve, если она была, вместе с ошибками остановки.
+func (m *Manager) Run(ctx context.Context) error {
+	m.mu.Lock()
+	components := append([]component(nil), m.components...)
+	m.mu.Unlock()
+
+	errCh := make(chan error, len(components))
+	for _, c := range components {
+		if c.serve == nil {
+			continue
+		}
+		go func(c component) {
+			if err := c.serve(); err != nil {
+				errCh <- fmt.Errorf("%s: %w", c.name, err)
+			}
+		}(c)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.Log.Info("shutting down")
+	case runErr = <-errCh:
+		logger.Log.Error("component failed, shutting down", zap.String("err", runErr.Error()))
+	}
+
+	return errors.Join(runErr, m.Shutdown())
+}
+
+// Shutdown останавливает компоненты в обратном порядке. Компонент, не успевший
+// к дедлайну, получает отменённый контекст, но следующие всё равно останавливаются.
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return nil
+	}
+	m.stopped = true
+	components := m.components
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if c.stop == nil {
+			continue
+		}
+		start := time.Now()
+		err := c.stop(ctx)
+		if err != nil {
+			logger.Log.Error("error to stop component", zap.String("component", c.name), zap.String("err", err.Error()))
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			continue
+		}
+		logger.Log.Info("component stopped", zap.String("component", c.name), zap.Duration("took", time.Since(start)))
+	}
+	return errors.Join(errs...)
+}
+
+// Wait приспосабливает блокирующую остановку без контекста (Close воркеров):
+// ждёт её не дольше дедлайна ctx, сама остановка при этом продолжается в фоне
+func Wait(stop func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
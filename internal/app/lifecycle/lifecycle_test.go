@@ -0,0 +1,114 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stopLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (l *stopLog) stop(name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.names = append(l.names, name)
+		return nil
+	}
+}
+
+func TestManager_drainsInFlightRequestsBeforeWorkers(t *testing.T) {
+	stops := &stopLog{}
+	m := New(time.Second)
+
+	m.OnStop("db pool", stops.stop("db pool"))
+	m.OnStop("worker", Wait(func() { stops.stop("worker")(context.Background()) }))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	m.Go("http server", func() error {
+		err := srv.Serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}, func(ctx context.Context) error {
+		err := srv.Shutdown(ctx)
+		stops.stop("http server")(ctx)
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() { runErr <- m.Run(ctx) }()
+
+	body := make(chan string)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	// запрос в обработке: останов ждёт его, а не обрывает
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "done", <-body)
+	require.NoError(t, <-runErr)
+	assert.Equal(t, []string{"http server", "worker", "db pool"}, stops.names)
+}
+
+func TestManager_deadlineDoesNotSkipLaterComponents(t *testing.T) {
+	stops := &stopLog{}
+	m := New(50 * time.Millisecond)
+
+	m.OnStop("db pool", stops.stop("db pool"))
+	m.OnStop("stuck worker", Wait(func() { time.Sleep(time.Second) }))
+
+	err := m.Shutdown()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stuck worker")
+	assert.Equal(t, []string{"db pool"}, stops.names)
+
+	// повторная остановка ничего не делает
+	assert.NoError(t, m.Shutdown())
+	assert.Equal(t, []string{"db pool"}, stops.names)
+}
+
+func TestManager_serveErrorTriggersShutdown(t *testing.T) {
+	stops := &stopLog{}
+	m := New(time.Second)
+
+	serveErr := errors.New("address already in use")
+	m.OnStop("worker", stops.stop("worker"))
+	m.Go("http server", func() error { return serveErr }, stops.stop("http server"))
+
+	err := m.Run(context.Background())
+	assert.ErrorIs(t, err, serveErr)
+	assert.Equal(t, []string{"http server", "worker"}, stops.names)
+}
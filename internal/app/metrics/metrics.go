@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortener"
+
+// Registry - собственный реестр вместо глобального, чтобы в выдаче были только наши метрики
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by chi route pattern, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by chi route pattern, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	gzipRatio = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_gzip_compression_ratio",
+		Help:      "Compressed to uncompressed size ratio of gzip encoded responses.",
+		Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+	})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of url storage operations by storage and method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"storage", "method"})
+
+	storageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_errors_total",
+		Help:      "Failed url storage operations by storage and method.",
+	}, []string{"storage", "method"})
+
+	linksCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "links_created_total",
+		Help:      "Short links created.",
+	})
+
+	conflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "link_conflicts_total",
+		Help:      "Shorten requests for urls that were already shortened.",
+	})
+
+	redirects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Successful short link resolutions.",
+	})
+
+	notFound = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "not_found_lookups_total",
+		Help:      "Lookups of unknown, deleted or expired short links.",
+	})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Short link cache lookups by result: hit, negative_hit or miss.",
+	}, []string{"result"})
+
+	cacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Short link cache entries evicted to stay within the size limit.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		gzipRatio,
+		storageDuration,
+		storageErrors,
+		linksCreated,
+		conflicts,
+		redirects,
+		notFound,
+		cacheLookups,
+		cacheEvictions,
+	)
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus. Сжатием занимается
+// gzip middleware основного роутера, иначе ответ сжимается дважды.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry, DisableCompression: true})
+}
+
+// RegisterDBStats добавляет статистику пула database/sql
+func RegisterDBStats(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Middleware считает запросы и их длительность. Маршрут берётся из шаблона chi
+// после обработки, чтобы /{id} не порождал по метке на каждый код.
+func Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+	return http.HandlerFunc(fn)
+}
+
+func ObserveGzip(uncompressed int64, compressed int64) {
+	if uncompressed > 0 {
+		gzipRatio.Observe(float64(compressed) / float64(uncompressed))
+	}
+}
+
+// ObserveStorage фиксирует длительность операции хранилища, начатой в start
+func ObserveStorage(storage string, method string, start time.Time, err error) {
+	storageDuration.WithLabelValues(storage, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(storage, method).Inc()
+	}
+}
+
+func LinkCreated() {
+	linksCreated.Inc()
+}
+
+func LinkConflict() {
+	conflicts.Inc()
+}
+
+func Redirect() {
+	redirects.Inc()
+}
+
+func NotFound() {
+	notFound.Inc()
+}
+
+// CacheLookup учитывает обращение к кешу ссылок с результатом hit, negative_hit или miss
+func CacheLookup(result string) {
+	cacheLookups.WithLabelValues(result).Inc()
+}
+
+func CacheEviction() {
+	cacheEvictions.Inc()
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_labelsByRoutePattern(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	for _, path := range []string{"/abc", "/def"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/a/b", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues("/{id}", http.MethodGet, "307")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues("unmatched", http.MethodPost, "404")))
+}
+
+func TestHandler(t *testing.T) {
+	LinkCreated()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "shortener_links_created_total 1")
+}
@@ -0,0 +1,88 @@
+// Package problem описывает ответы об ошибках в формате RFC 7807 (application/problem+json)
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const ContentType = "application/problem+json"
+
+// Стабильные коды ошибок, на которые могут опираться клиенты
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeInvalidURL        = "invalid_url"
+	CodeInvalidExpiration = "invalid_expiration"
+	CodeBatchTooLarge     = "batch_too_large"
+	CodeInvalidAlias      = "invalid_alias"
+	CodeReservedAlias     = "reserved_alias"
+	CodeAliasTaken        = "alias_taken"
+	CodeConflict          = "conflict"
+	CodeNotFound          = "not_found"
+	CodeGone              = "gone"
+	CodeUnauthorized      = "unauthorized"
+	CodeForbidden         = "forbidden"
+	CodeRateLimited       = "rate_limited"
+	CodeUnavailable       = "unavailable"
+	CodeInternal          = "internal"
+)
+
+const typePrefix = "urn:go-yandex-shortener:problem:"
+
+// Problem - тело ответа об ошибке. Code дублирует окончание Type для удобства клиентов,
+// Result при конфликте содержит уже существующую короткую ссылку.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	Result    string `json:"result,omitempty"`
+}
+
+// New собирает описание ошибки запроса r
+func New(r *http.Request, status int, code string, detail string) *Problem {
+	p := &Problem{
+		Type:     typePrefix + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	}
+	if id, ok := logger.RequestIDFromContext(r.Context()); ok {
+		p.RequestID = id
+	}
+	return p
+}
+
+func (p *Problem) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	err := json.NewEncoder(w).Encode(p)
+	if err != nil {
+		logger.Log.Error("error to write problem", zap.String("err", err.Error()))
+	}
+}
+
+// Write отвечает на запрос r ошибкой с кодом code
+func Write(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	New(r, status, code, detail).Write(w)
+}
+
+// AcceptsJSON сообщает, что клиент явно просит JSON (application/json или application/problem+json)
+func AcceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "application/json") || strings.Contains(accept, ContentType) {
+			return true
+		}
+	}
+	return false
+}
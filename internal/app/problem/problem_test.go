@@ -0,0 +1,54 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+func TestWrite(t *testing.T) {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusNotFound, CodeNotFound, "short url not found")
+	})
+	handler = logger.RequestLogger(handler)
+
+	request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	request.Header.Set(logger.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, Problem{
+		Type:      "urn:go-yandex-shortener:problem:not_found",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "short url not found",
+		Instance:  "/abc",
+		Code:      CodeNotFound,
+		RequestID: "req-1",
+	}, p)
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"text/plain":                        false,
+		"application/json":                  true,
+		"application/problem+json":          true,
+		"text/html, application/json;q=0.9": true,
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set("Accept", accept)
+		assert.Equal(t, want, AcceptsJSON(request), accept)
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit - скорость пополнения ведра в запросах в секунду и его ёмкость
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Result - решение по запросу и данные для заголовков RateLimit-*
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter - через сколько появится токен для следующего запроса
+	RetryAfter time.Duration
+	// Reset - через сколько ведро наполнится полностью
+	Reset time.Duration
+}
+
+// Take пополняет ведро с tokens токенами, обновлённое в last, на момент now и пытается
+// взять из него один токен. Возвращает новое число токенов и решение.
+// Новое ведро (нулевой last) считается полным.
+func Take(tokens float64, last time.Time, limit Limit, now time.Time) (float64, Result) {
+	burst := float64(limit.Burst)
+	if last.IsZero() {
+		tokens = burst
+	} else if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*limit.Rate)
+	}
+
+	res := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = secondsToDuration((burst - tokens) / limit.Rate)
+	return tokens, res
+}
+
+// idleFull возвращает время простоя, после которого ведро гарантированно полное
+// и его можно забыть без изменения поведения
+func idleFull(limit Limit) time.Duration {
+	return secondsToDuration(float64(limit.Burst) / limit.Rate)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
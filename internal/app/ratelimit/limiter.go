@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// KeyFunc определяет, чей лимит расходует запрос
+type KeyFunc func(r *http.Request) string
+
+// Limiter - middleware с отдельным ведром на каждого клиента
+type Limiter struct {
+	name  string
+	store Store
+	limit Limit
+	key   KeyFunc
+}
+
+// NewLimiter создаёт лимитер. name разделяет вёдра разных лимитов в общем хранилище.
+func NewLimiter(name string, store Store, limit Limit, key KeyFunc) *Limiter {
+	return &Limiter{name: name, store: store, limit: limit, key: key}
+}
+
+// Middleware отвечает 429 с Retry-After, когда ведро клиента пусто.
+// Если хранилище недоступно, запрос пропускается: лимит не должен ронять сервис.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if !l.limit.Enabled() {
+		return next
+	}
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		res, err := l.store.Take(r.Context(), l.name+":"+l.key(r), l.limit, time.Now())
+		if err != nil {
+			logger.FromContext(r.Context()).Error("error to check rate limit", zap.String("err", err.Error()))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			problem.Write(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, l.name+" rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
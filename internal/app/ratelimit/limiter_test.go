@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var tokens float64
+	var last time.Time
+	var res Result
+	for i := 0; i < 3; i++ {
+		tokens, res = Take(tokens, last, limit, start)
+		last = start
+		require.True(t, res.Allowed)
+	}
+	assert.Equal(t, 0, res.Remaining)
+
+	tokens, res = Take(tokens, last, limit, start)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	// через полсекунды накопился ровно один токен
+	_, res = Take(tokens, last, limit, start.Add(500*time.Millisecond))
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_evictsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 5}
+	now := time.Now()
+
+	_, err := store.Take(context.Background(), "a", limit, now)
+	require.NoError(t, err)
+	_, err = store.Take(context.Background(), "b", limit, now.Add(sweepInterval-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.size())
+
+	// к следующей чистке ведро "a" давно полное, а "b" ещё нет
+	_, err = store.Take(context.Background(), "c", Limit{Rate: 0.01, Burst: 5}, now.Add(sweepInterval))
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.size())
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (Result, error) {
+	return Result{}, errors.New("db is down")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	byHeader := func(r *http.Request) string { return r.Header.Get("X-Client") }
+	handler := NewLimiter("write", NewMemoryStore(), Limit{Rate: 1, Burst: 2}, byHeader).Middleware(ok)
+
+	send := func(client string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", nil)
+		request.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, send("first").Code)
+	w := send("first")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = send("first")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+
+	// у другого клиента своё ведро
+	assert.Equal(t, http.StatusCreated, send("second").Code)
+
+	t.Run("disabled limit", func(t *testing.T) {
+		handler := NewLimiter("write", failingStore{}, Limit{}, byHeader).Middleware(ok)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("store failure lets requests through", func(t *testing.T) {
+		handler := NewLimiter("write", failingStore{}, Limit{Rate: 1, Burst: 1}, byHeader).Middleware(ok)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	idle   time.Duration
+}
+
+// MemoryStore хранит вёдра в памяти процесса. Вёдра, которые успели наполниться,
+// периодически вычищаются, поэтому память не растёт от разовых клиентов.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{idle: idleFull(limit)}
+		s.buckets[key] = b
+	}
+	tokens, res := Take(b.tokens, b.last, limit, now)
+	b.tokens, b.last = tokens, now
+	return res, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= b.idle {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (s *MemoryStore) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
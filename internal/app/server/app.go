@@ -3,27 +3,43 @@ package server
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
-	"log"
+	"google.golang.org/grpc"
+	"net"
 	"net/http"
-	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clicks"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/connection"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/migrations"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/grpcserver"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/lifecycle"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/handlers"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/tlscert"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/tracing"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
 )
 
 type App struct {
 	httpServer *http.Server
+	grpcServer *grpc.Server
 	dbPool     *sql.DB
 	services   *service.Services
+	urlRemover *shortener.URLRemover
+	reaper     *shortener.Reaper
+	clicks     *clicks.Recorder
+	lifecycle  *lifecycle.Manager
 }
 
 func NewApp(cfg *config.Config) (*App, error) {
@@ -36,6 +52,15 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, errs.ErrCreateDBPoll
 	}
 
+	migrator, err := migrations.NewMigrator(pool)
+	if err == nil {
+		err = migrator.Up(context.Background())
+	}
+	if err != nil {
+		logger.Log.Error("error to migrate db", zap.String("err", err.Error()))
+		return nil, errs.ErrMigrateDB
+	}
+
 	pgServices, err := service.NewPostgresServices(pool)
 	if err != nil {
 		logger.Log.Error("error to create service", zap.String("err", err.Error()))
@@ -48,56 +73,207 @@ func NewApp(cfg *config.Config) (*App, error) {
 	}, nil
 }
 
+// Run поднимает серверы и фоновые воркеры и блокируется до SIGINT/SIGTERM/SIGQUIT,
+// после чего останавливает всё через lifecycle.Manager
 func (a *App) Run(cfg *config.Config) error {
-	err := logger.Initialize(zap.InfoLevel)
+	err := logger.Initialize(cfg.LogLevel, cfg.LogFormat)
 	if err != nil {
 		return err
 	}
 
+	a.lifecycle = lifecycle.New(cfg.ShutdownTimeout)
+	// пул закрывается последним, когда воркеры уже сбросили данные
+	a.lifecycle.OnStop("db pool", a.closeDBPool)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter, cfg.TraceEndpoint)
+	if err != nil {
+		return errors.Join(err, a.lifecycle.Shutdown())
+	}
+	a.lifecycle.OnStop("tracer provider", shutdownTracing)
+
+	err = a.setUp(cfg)
+	if err != nil {
+		return errors.Join(err, a.lifecycle.Shutdown())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	return a.lifecycle.Run(ctx)
+}
+
+func (a *App) setUp(cfg *config.Config) error {
 	router := chi.NewRouter()
-	router.Use(middleware.Logger)
+	router.Use(tracing.Middleware)
+	router.Use(logger.RequestLogger)
+	router.Use(metrics.Middleware)
 	router.Use(gzipMiddleware)
 
-	err = handlers.RegisterHTTPEndpoint(router, a.services, cfg)
+	if a.dbPool != nil {
+		err := metrics.RegisterDBStats(a.dbPool)
+		if err != nil {
+			return err
+		}
+	}
+
+	mapper, err := handlers.NewURLShortener(a.services, cfg)
+	if err != nil {
+		logger.Log.Error("error to create url shortener", zap.String("err", err.Error()))
+		return errs.ErrRegisterEndpoints
+	}
+
+	// хранилище закрывается после воркеров, которые в него пишут
+	a.lifecycle.OnStop("url storage", func(context.Context) error {
+		return mapper.Close()
+	})
+
+	a.urlRemover = shortener.NewURLRemover(mapper)
+	a.lifecycle.OnStop("url remover", lifecycle.Wait(a.urlRemover.Close))
+
+	a.reaper = shortener.NewReaper(mapper, cfg.ReaperInterval)
+	a.reaper.Start()
+	a.lifecycle.OnStop("reaper", lifecycle.Wait(a.reaper.Stop))
+
+	clickStore, err := handlers.NewClickStore(a.services, cfg)
 	if err != nil {
+		logger.Log.Error("error to create click store", zap.String("err", err.Error()))
 		return errs.ErrRegisterEndpoints
 	}
+	a.clicks = clicks.NewRecorder(clickStore)
+	a.lifecycle.OnStop("click recorder", lifecycle.Wait(a.clicks.Close))
+
+	authenticator := auth.NewAuthenticator(cfg.SecretKey)
+
+	err = handlers.RegisterHTTPEndpoint(router, mapper, a.urlRemover, a.clicks, authenticator, a.services, cfg)
+	if err != nil {
+		return errs.ErrRegisterEndpoints
+	}
+	// chi требует объявлять маршруты после всех middleware, которые добавляет RegisterHTTPEndpoint
+	if cfg.AdminAddr == "" {
+		// без отдельного листенера смену уровня логов пускаем только из доверенной подсети
+		trustedSubnet, err := clientip.ParseSubnet(cfg.TrustedSubnet)
+		if err != nil {
+			return err
+		}
+		router.Handle("/metrics", metrics.Handler())
+		router.With(trustedSubnet.Middleware).Handle("/admin/loglevel", logger.LevelHandler())
+	} else {
+		a.serveAdmin(cfg.AdminAddr)
+	}
 
 	a.httpServer = &http.Server{
 		Addr:           cfg.Addr,
+		Handler:        router,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	go func() {
-		err := http.ListenAndServe(
-			cfg.Addr,
-			router,
-		)
+	serve, err := a.serveFunc(cfg)
+	if err != nil {
+		logger.Log.Error("error to configure tls", zap.String("err", err.Error()))
+		return err
+	}
+	a.lifecycle.Go("http server", func() error {
+		err := serve()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}, a.httpServer.Shutdown)
+
+	if cfg.GRPCAddr != "" {
+		a.grpcServer, err = grpcserver.New(mapper, a.urlRemover, authenticator, cfg)
+		if err != nil {
+			logger.Log.Error("error to create grpc server", zap.String("err", err.Error()))
+			return errs.ErrRegisterEndpoints
+		}
+		listener, err := net.Listen("tcp", cfg.GRPCAddr)
 		if err != nil {
-			log.Fatalf("Failed to listen and serve: %+v", err)
+			return err
 		}
-	}()
+		a.lifecycle.Go("grpc server", func() error {
+			return a.grpcServer.Serve(listener)
+		}, a.stopGRPC)
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, os.Interrupt)
+	return nil
+}
 
-	<-quit
+// serveFunc выбирает запуск по http или https. Без файлов сертификата
+// используется самоподписанный сертификат, закешированный на диске.
+func (a *App) serveFunc(cfg *config.Config) (func() error, error) {
+	if !cfg.EnableHTTPS {
+		return a.httpServer.ListenAndServe, nil
+	}
 
-	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
-	defer shutdown()
+	tlsConfig, err := tlscert.Config(cfg.TLSMinVersion, cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	a.httpServer.TLSConfig = tlsConfig
 
-	return a.httpServer.Shutdown(ctx)
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+	if (certFile == "") != (keyFile == "") {
+		return nil, errs.ErrTLSKeyPair
+	}
+	if certFile == "" {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		certFile, keyFile, err = tlscert.EnsureSelfSigned(tlscert.DefaultDir(), []string{host})
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.Info("serving with self-signed certificate", zap.String("cert", certFile))
+	}
 
+	return func() error {
+		return a.httpServer.ListenAndServeTLS(certFile, keyFile)
+	}, nil
 }
 
-func (a *App) CloseDBPool() {
+// serveAdmin поднимает отдельный служебный листенер, недоступный снаружи вместе с основным API
+func (a *App) serveAdmin(addr string) {
+	router := chi.NewRouter()
+	router.Use(logger.RequestLogger)
+	router.Handle("/metrics", metrics.Handler())
+	router.Handle("/admin/loglevel", logger.LevelHandler())
+
+	adminServer := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	a.lifecycle.Go("admin server", func() error {
+		err := adminServer.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}, adminServer.Shutdown)
+}
+
+// stopGRPC дожидается завершения текущих вызовов, а по истечении ctx обрывает их
+func (a *App) stopGRPC(ctx context.Context) error {
+	err := lifecycle.Wait(a.grpcServer.GracefulStop)(ctx)
+	if err != nil {
+		a.grpcServer.Stop()
+	}
+	return err
+}
+
+func (a *App) closeDBPool(_ context.Context) error {
 	if a.dbPool == nil {
-		return
+		return nil
 	}
-	err := a.dbPool.Close()
+	pool := a.dbPool
+	a.dbPool = nil
+	return pool.Close()
+}
+
+// CloseDBPool закрывает пул, если приложение не дошло до Run
+func (a *App) CloseDBPool() {
+	err := a.closeDBPool(context.Background())
 	if err != nil {
-		return
+		logger.Log.Error("error to close db pool", zap.String("err", err.Error()))
 	}
 }
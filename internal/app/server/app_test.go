@@ -0,0 +1,195 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
+	dbErrs "github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/lifecycle"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Addr:             "localhost:0",
+		PrefixURL:        "http://localhost:8080",
+		SecretKey:        "secret",
+		CodeStrategy:     codegen.StrategyRandom,
+		CodeLength:       5,
+		CodeAlphabet:     codegen.Base62Alphabet,
+		FileSync:         "never",
+		RateLimitStore:   config.RateLimitStoreMemory,
+		ShutdownTimeout:  time.Second,
+		CacheSize:        100,
+		CacheTTL:         time.Minute,
+		CacheNegativeTTL: time.Second,
+		BatchMaxSize:     100,
+	}
+}
+
+// setUpApp собирает приложение без запуска листенеров и возвращает его роутер
+func setUpApp(t *testing.T, a *App, cfg *config.Config) http.Handler {
+	a.lifecycle = lifecycle.New(cfg.ShutdownTimeout)
+	t.Cleanup(func() {
+		assert.NoError(t, a.lifecycle.Shutdown())
+	})
+
+	require.NoError(t, a.setUp(cfg))
+	return a.httpServer.Handler
+}
+
+// shortenAndFollow сокращает адрес и проходит по полученной ссылке
+func shortenAndFollow(t *testing.T, router http.Handler, cfg *config.Config) {
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://ya.ru"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	shortURL := w.Body.String()
+	require.True(t, strings.HasPrefix(shortURL, cfg.PrefixURL+"/"), shortURL)
+
+	request = httptest.NewRequest(http.MethodGet, strings.TrimPrefix(shortURL, cfg.PrefixURL), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://ya.ru", w.Header().Get("Location"))
+
+	request = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "shortener_http_requests_total")
+}
+
+func TestApp_setUpMemory(t *testing.T) {
+	cfg := testConfig()
+	router := setUpApp(t, &App{}, cfg)
+
+	shortenAndFollow(t, router, cfg)
+
+	request := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+type stubPingService struct{}
+
+func (stubPingService) PingDB(context.Context) error {
+	return nil
+}
+
+// stubURLService хранит ссылки в памяти вместо postgres
+type stubURLService struct {
+	service.URLService
+
+	mu   sync.Mutex
+	urls map[string]models.URL
+}
+
+func (s *stubURLService) SaveURL(_ context.Context, url models.URL) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existed := range s.urls {
+		if existed.OriginalURL == url.OriginalURL {
+			return existed.ShortURL, dbErrs.ErrOriginalURLAlreadyExist
+		}
+	}
+	if _, ok := s.urls[url.ShortURL]; ok {
+		return "", dbErrs.ErrShortURLAlreadyExist
+	}
+	s.urls[url.ShortURL] = url
+	return url.ShortURL, nil
+}
+
+func (s *stubURLService) GetURL(_ context.Context, shortURL string) (*models.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[shortURL]
+	if !ok {
+		return nil, dbErrs.ErrURLNotFound
+	}
+	return &url, nil
+}
+
+func (s *stubURLService) PurgeExpired(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+type stubClickService struct {
+	service.ClickService
+}
+
+func (stubClickService) SaveClicks(context.Context, []models.Click) error {
+	return nil
+}
+
+func TestApp_setUpDatabase(t *testing.T) {
+	cfg := testConfig()
+	cfg.DatabaseDSN = "postgres://localhost:1/shortener?sslmode=disable"
+
+	// пул не подключается, пока к нему не обратятся, а все обращения идут через заглушки
+	pool, err := sql.Open("postgres", cfg.DatabaseDSN)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		metrics.Registry.Unregister(collectors.NewDBStatsCollector(pool, "shortener"))
+	})
+
+	a := &App{
+		dbPool: pool,
+		services: &service.Services{
+			PingService:  stubPingService{},
+			URLService:   &stubURLService{urls: make(map[string]models.URL)},
+			ClickService: stubClickService{},
+		},
+	}
+	router := setUpApp(t, a, cfg)
+	t.Cleanup(a.CloseDBPool)
+
+	shortenAndFollow(t, router, cfg)
+
+	request := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Contains(t, w.Body.String(), "go_sql_open_connections")
+}
+
+// Метрики сжимаются один раз: gzip middleware роутера, а не сам promhttp
+func TestApp_metricsGzip(t *testing.T) {
+	router := setUpApp(t, &App{}, testConfig())
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "# HELP shortener_")
+}
@@ -3,6 +3,18 @@ package errs
 import "fmt"
 
 var ErrConflictOriginalURL = fmt.Errorf("original Url Already Exist")
+var ErrInvalidAlias = fmt.Errorf("alias must be 3-64 latin letters, digits, '_' or '-'")
+var ErrReservedAlias = fmt.Errorf("alias is reserved")
+var ErrAliasTaken = fmt.Errorf("alias already taken")
+var ErrInvalidURL = fmt.Errorf("url must be an absolute http or https url")
+var ErrBatchTooLarge = fmt.Errorf("batch is too large")
 var ErrCreateDBPoll = fmt.Errorf("error creating db pool")
+var ErrMigrateDB = fmt.Errorf("error migrating db schema")
 var ErrCreateServices = fmt.Errorf("error creating db services")
 var ErrRegisterEndpoints = fmt.Errorf("error regestration http endpoints")
+var ErrTLSKeyPair = fmt.Errorf("tls certificate and key files must be set together")
+var ErrNotFound = fmt.Errorf("short url not found")
+var ErrGone = fmt.Errorf("short url is deleted or expired")
+var ErrUnavailable = fmt.Errorf("url storage is unavailable")
+var ErrRemoverBusy = fmt.Errorf("delete queue is full")
+var ErrRemoverClosed = fmt.Errorf("url remover is closed")
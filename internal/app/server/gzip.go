@@ -5,19 +5,29 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
 )
 
 // compressWriter реализует интерфейс http.ResponseWriter и позволяет прозрачно для сервера
 // сжимать передаваемые данные и выставлять правильные HTTP-заголовки
 type compressWriter struct {
-	w  http.ResponseWriter
-	zw *gzip.Writer
+	w          http.ResponseWriter
+	zw         *gzip.Writer
+	compressed *countingWriter
+	written    int64
+	// wroteHeader - статус уже отправлен, bypass - тело отдаётся без сжатия
+	wroteHeader bool
+	bypass      bool
 }
 
 func newCompressWriter(w http.ResponseWriter) *compressWriter {
+	compressed := &countingWriter{w: w}
 	return &compressWriter{
-		w:  w,
-		zw: gzip.NewWriter(w),
+		w:          w,
+		zw:         gzip.NewWriter(compressed),
+		compressed: compressed,
 	}
 }
 
@@ -26,19 +36,54 @@ func (c *compressWriter) Header() http.Header {
 }
 
 func (c *compressWriter) Write(p []byte) (int, error) {
-	return c.zw.Write(p)
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.bypass {
+		return c.w.Write(p)
+	}
+	n, err := c.zw.Write(p)
+	c.written += int64(n)
+	return n, err
 }
 
+// WriteHeader сжимает только успешные ответы с телом. Ошибки, конфликты и редиректы
+// уходят как есть, чтобы тело всегда соответствовало заголовку Content-Encoding.
 func (c *compressWriter) WriteHeader(statusCode int) {
-	if statusCode < 300 {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	if statusCode >= 300 || statusCode == http.StatusNoContent {
+		c.bypass = true
+	} else {
 		c.w.Header().Set("Content-Encoding", "gzip")
+		c.w.Header().Del("Content-Length")
 	}
 	c.w.WriteHeader(statusCode)
 }
 
 // Close закрывает gzip.Writer и досылает все данные из буфера.
 func (c *compressWriter) Close() error {
-	return c.zw.Close()
+	if !c.wroteHeader || c.bypass {
+		// хендлер ничего не отправил или ответ ушёл без сжатия
+		return nil
+	}
+	err := c.zw.Close()
+	metrics.ObserveGzip(c.written, c.compressed.n)
+	return err
+}
+
+// countingWriter считает байты, ушедшие клиенту после сжатия
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // compressReader реализует интерфейс io.ReadCloser и позволяет прозрачно для сервера
@@ -97,7 +142,7 @@ func gzipMiddleware(next http.Handler) http.Handler {
 				// оборачиваем тело запроса в io.Reader с поддержкой декомпрессии
 				cr, err := newCompressReader(r.Body)
 				if err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
+					problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "unable to decode gzip request body")
 					return
 				}
 				// меняем тело запроса на новое
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+)
+
+func TestGzipMiddleware_responses(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		status     int
+		compressed bool
+		body       string
+	}{
+		{
+			name: "implicit ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			},
+			status:     http.StatusOK,
+			compressed: true,
+			body:       "hello",
+		},
+		{
+			name: "created",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("http://localhost/abcde"))
+			},
+			status:     http.StatusCreated,
+			compressed: true,
+			body:       "http://localhost/abcde",
+		},
+		{
+			name: "conflict",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte("http://localhost/abcde"))
+			},
+			status: http.StatusConflict,
+			body:   "http://localhost/abcde",
+		},
+		{
+			name: "problem",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "short url not found")
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "https://ya.ru", http.StatusTemporaryRedirect)
+			},
+			status: http.StatusTemporaryRedirect,
+		},
+		{
+			name:    "empty",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			status:  http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Accept", "application/json")
+			request.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+
+			gzipMiddleware(tt.handler).ServeHTTP(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+
+			body := io.Reader(res.Body)
+			if tt.compressed {
+				assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+				zr, err := gzip.NewReader(res.Body)
+				require.NoError(t, err)
+				body = zr
+			} else {
+				assert.Empty(t, res.Header.Get("Content-Encoding"))
+			}
+			data, err := io.ReadAll(body)
+			require.NoError(t, err)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, string(data))
+			}
+			if tt.status == http.StatusNotFound {
+				assert.Contains(t, string(data), problem.CodeNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// batchLimitedShortener отклоняет слишком большие пачки целиком, не обращаясь к хранилищу
+type batchLimitedShortener struct {
+	URLShortener
+	maxSize int
+}
+
+func newBatchLimitedShortener(next URLShortener, maxSize int) URLShortener {
+	if maxSize <= 0 {
+		return next
+	}
+	return &batchLimitedShortener{URLShortener: next, maxSize: maxSize}
+}
+
+func (s *batchLimitedShortener) AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error) {
+	if len(urls) > s.maxSize {
+		return nil, fmt.Errorf("%w: %d urls, at most %d allowed", errs.ErrBatchTooLarge, len(urls), s.maxSize)
+	}
+	return s.URLShortener.AddBatch(ctx, urls)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/cache"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// CacheOptions задают размер кеша ссылок и сроки жизни найденных и ненайденных кодов
+type CacheOptions struct {
+	Size        int
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+type cachedURL struct {
+	url models.URL
+	err error
+}
+
+// cachedShortener кеширует чтение ссылок по коду. Одновременные промахи по одному
+// коду схлопываются в одно обращение к хранилищу, ненайденные коды тоже запоминаются,
+// а сбои хранилища нет.
+// Записи сбрасываются при создании и удалении ссылок через этот же экземпляр,
+// изменения с других инстансов видны по истечении TTL.
+type cachedShortener struct {
+	URLShortener
+	cache   *cache.LRU[string, cachedURL]
+	loads   cache.Group[string, cachedURL]
+	options CacheOptions
+	// generation растёт при каждой инвалидации, чтобы не положить в кеш прочитанное до неё
+	generation atomic.Uint64
+}
+
+func newCachedShortener(next URLShortener, options CacheOptions) URLShortener {
+	if options.Size <= 0 {
+		return next
+	}
+	return &cachedShortener{
+		URLShortener: next,
+		cache:        cache.NewLRU[string, cachedURL](options.Size, metrics.CacheEviction),
+		options:      options,
+	}
+}
+
+func (s *cachedShortener) Get(ctx context.Context, shortURL string) (models.URL, error) {
+	if cached, ok := s.cache.Get(shortURL); ok {
+		if errors.Is(cached.err, errs.ErrNotFound) {
+			metrics.CacheLookup("negative_hit")
+		} else {
+			metrics.CacheLookup("hit")
+		}
+		return cached.url, cached.err
+	}
+	metrics.CacheLookup("miss")
+
+	// загрузка не должна оборваться из-за отмены запроса, результат которого ждут другие
+	ctx = context.WithoutCancel(ctx)
+	cached, _, _ := s.loads.Do(shortURL, func() (cachedURL, error) {
+		generation := s.generation.Load()
+		url, err := s.URLShortener.Get(ctx, shortURL)
+		cached := cachedURL{url: url, err: err}
+		if (err == nil || isExpectedLookupError(err)) && s.generation.Load() == generation {
+			s.cache.Set(shortURL, cached, s.ttl(cached, time.Now()))
+		}
+		return cached, nil
+	})
+	return cached.url, cached.err
+}
+
+func (s *cachedShortener) Add(ctx context.Context, url models.URL) (string, error) {
+	shortURL, err := s.URLShortener.Add(ctx, url)
+	s.invalidate(url.ShortURL, shortURL)
+	return shortURL, err
+}
+
+func (s *cachedShortener) AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error) {
+	results, err := s.URLShortener.AddBatch(ctx, urls)
+	for _, url := range urls {
+		s.invalidate(url.ShortURL)
+	}
+	for _, result := range results {
+		s.invalidate(result.ShortURL)
+	}
+	return results, err
+}
+
+func (s *cachedShortener) Delete(ctx context.Context, userID string, shortURLs []string) error {
+	err := s.URLShortener.Delete(ctx, userID, shortURLs)
+	s.invalidate(shortURLs...)
+	return err
+}
+
+func (s *cachedShortener) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	n, err := s.URLShortener.PurgeExpired(ctx, now)
+	if n > 0 {
+		s.generation.Add(1)
+		s.cache.Purge()
+	}
+	return n, err
+}
+
+func (s *cachedShortener) invalidate(shortURLs ...string) {
+	s.generation.Add(1)
+	for _, shortURL := range shortURLs {
+		if shortURL == "" {
+			continue
+		}
+		s.loads.Forget(shortURL)
+		s.cache.Delete(shortURL)
+	}
+}
+
+// ttl не даёт живой ссылке пробыть в кеше дольше её собственного срока
+func (s *cachedShortener) ttl(cached cachedURL, now time.Time) time.Duration {
+	if errors.Is(cached.err, errs.ErrNotFound) {
+		return s.options.NegativeTTL
+	}
+	if cached.err == nil && cached.url.ExpiresAt != nil {
+		return min(s.options.TTL, cached.url.ExpiresAt.Sub(now))
+	}
+	return s.options.TTL
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// slowShortener считает обращения к хранилищу и имитирует задержку запроса к БД
+type slowShortener struct {
+	URLShortener
+	gets  atomic.Int64
+	delay time.Duration
+}
+
+func (s *slowShortener) Get(ctx context.Context, shortURL string) (models.URL, error) {
+	s.gets.Add(1)
+	if s.delay > 0 {
+		time.Sleep(s.delay)
+	}
+	return s.URLShortener.Get(ctx, shortURL)
+}
+
+func newSlowShortener(t testing.TB, delay time.Duration) *slowShortener {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 8), t.TempDir()+"/db.json")
+	return &slowShortener{URLShortener: mapper, delay: delay}
+}
+
+var testCacheOptions = CacheOptions{Size: 100, TTL: time.Minute, NegativeTTL: time.Minute}
+
+func TestCachedShortener_Get(t *testing.T) {
+	ctx := context.Background()
+	backend := newSlowShortener(t, 0)
+	cached := newCachedShortener(backend, testCacheOptions)
+
+	shortURL, err := cached.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		url, err := cached.Get(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, "https://ya.ru", url.OriginalURL)
+	}
+	assert.Equal(t, int64(1), backend.gets.Load())
+
+	t.Run("delete invalidates", func(t *testing.T) {
+		require.NoError(t, cached.Delete(ctx, "user", []string{shortURL}))
+		url, err := cached.Get(ctx, shortURL)
+		assert.ErrorIs(t, err, errs.ErrGone)
+		assert.True(t, url.DeletedFlag)
+	})
+
+	t.Run("negative entry is dropped when alias is taken", func(t *testing.T) {
+		_, err := cached.Get(ctx, "my-alias")
+		assert.ErrorIs(t, err, errs.ErrNotFound)
+		_, err = cached.Get(ctx, "my-alias")
+		assert.ErrorIs(t, err, errs.ErrNotFound)
+		gets := backend.gets.Load()
+
+		_, err = cached.Add(ctx, models.URL{ShortURL: "my-alias", OriginalURL: "https://example.com"})
+		require.NoError(t, err)
+
+		url, err := cached.Get(ctx, "my-alias")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", url.OriginalURL)
+		assert.Equal(t, gets+1, backend.gets.Load())
+	})
+}
+
+func TestCachedShortener_coalescesMisses(t *testing.T) {
+	ctx := context.Background()
+	backend := newSlowShortener(t, 50*time.Millisecond)
+	cached := newCachedShortener(backend, testCacheOptions)
+	shortURL, err := cached.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cached.Get(ctx, shortURL)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), backend.gets.Load())
+}
+
+// Бенчмарки сравнивают чтение популярных ссылок с задержкой хранилища в 100µs с кешем и без
+func BenchmarkGet(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		options CacheOptions
+	}{
+		{name: "uncached", options: CacheOptions{}},
+		{name: "cached", options: testCacheOptions},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			mapper := newCachedShortener(newSlowShortener(b, 100*time.Microsecond), bm.options)
+			codes := make([]string, 50)
+			for i := range codes {
+				code, err := mapper.Add(ctx, models.URL{OriginalURL: "https://example.com/" + codegen.Base62Alphabet[i:i+1]})
+				require.NoError(b, err)
+				codes[i] = code
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					mapper.Get(ctx, codes[i%len(codes)])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -4,25 +4,37 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
 )
 
 type Handler struct {
 	urlShortener URLShortener
+	urlRemover   URLRemover
+	clickTracker ClickTracker
 	prefixURL    string
 }
 
 func NewHandler(
 	urlShortener URLShortener,
+	urlRemover URLRemover,
+	clickTracker ClickTracker,
 	prefixURL string,
 ) *Handler {
 	return &Handler{
 		urlShortener: urlShortener,
+		urlRemover:   urlRemover,
+		clickTracker: clickTracker,
 		prefixURL:    prefixURL + "/",
 	}
 }
@@ -32,23 +44,39 @@ func (h *Handler) createShortURL(w http.ResponseWriter, r *http.Request) {
 
 	url, err := readBody(r.Body)
 	if err != nil {
-		logger.Log.Error("error to read body", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("error to read body", zap.String("err", err.Error()))
+		writeTextError(w, r, problem.New(r, http.StatusBadRequest, problem.CodeInvalidRequest, "unable to read request body"))
 		return
 	}
 	if isURLEmpty(url) {
-		w.WriteHeader(http.StatusBadRequest)
+		writeTextError(w, r, problem.New(r, http.StatusBadRequest, problem.CodeInvalidURL, "url must not be empty"))
 		return
 	}
-	shortURL, err := h.urlShortener.Add(r.Context(), url)
+	if err := shortener.ValidateOriginalURL(url); err != nil {
+		writeTextError(w, r, problem.New(r, http.StatusBadRequest, problem.CodeInvalidURL, err.Error()))
+		return
+	}
+	userID, _ := auth.UserIDFromContext(r.Context())
+	shortURL, err := h.urlShortener.Add(r.Context(), models.URL{OriginalURL: url, UserID: userID})
 	if errors.Is(err, errs.ErrConflictOriginalURL) {
-		logger.Log.Info("original url already exist", zap.String("err", err.Error()))
+		logger.FromContext(r.Context()).Info("original url already exist", zap.String("err", err.Error()))
+		metrics.LinkConflict()
+		if problem.AcceptsJSON(r) {
+			h.conflict(r, shortURL).Write(w)
+			return
+		}
+		// текстовые клиенты по-прежнему получают существующую ссылку телом ответа
+		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusConflict)
-	} else if err != nil {
-		logger.Log.Error("error to create short url", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(h.prefixURL + shortURL))
 		return
 	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to create short url", zap.String("err", err.Error()))
+		writeTextError(w, r, problem.New(r, http.StatusInternalServerError, problem.CodeInternal, "unable to create short url"))
+		return
+	}
+	metrics.LinkCreated()
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusCreated)
@@ -59,24 +87,74 @@ func (h *Handler) getURL(w http.ResponseWriter, r *http.Request) {
 	shortURL := chi.URLParam(r, "id")
 
 	if isURLEmpty(shortURL) {
-		logger.Log.Error("shortURL not found")
-		w.WriteHeader(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("shortURL not found")
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "short url code must not be empty")
+		return
+	}
+
+	url, err := h.urlShortener.Get(r.Context(), shortURL)
+	if err == nil && isURLEmpty(url.OriginalURL) {
+		err = errs.ErrNotFound
+	}
+	if err != nil {
+		if isExpectedLookupError(err) {
+			metrics.NotFound()
+		} else {
+			logger.FromContext(r.Context()).Error("error to get url", zap.String("err", err.Error()))
+		}
+		writeLookupError(w, r, err)
 		return
 	}
+	w.Header().Set("Location", url.OriginalURL)
+	w.WriteHeader(http.StatusTemporaryRedirect)
+	metrics.Redirect()
 
-	url, ok := h.urlShortener.Get(r.Context(), shortURL)
+	h.clickTracker.Record(models.Click{
+		ShortURL:  shortURL,
+		Time:      time.Now(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	})
+}
+
+func (h *Handler) getLinkStats(w http.ResponseWriter, r *http.Request) {
+	shortURL := chi.URLParam(r, "id")
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		logger.Log.Error("error to get url")
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "user cookie is missing or invalid")
 		return
 	}
-	if isURLEmpty(url) {
-		logger.Log.Error("URL not found")
-		w.WriteHeader(http.StatusBadRequest)
+
+	// статистика удалённых и истёкших ссылок остаётся доступной владельцу
+	url, err := h.urlShortener.Get(r.Context(), shortURL)
+	if err != nil && !errors.Is(err, errs.ErrGone) {
+		if !isExpectedLookupError(err) {
+			logger.FromContext(r.Context()).Error("error to get url", zap.String("err", err.Error()))
+		}
+		writeLookupError(w, r, err)
 		return
 	}
-	w.Header().Set("Location", url)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	// в статистике адреса и браузеры посетителей: её видит только автор ссылки
+	if url.UserID == "" || url.UserID != userID {
+		problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "link stats are available only to the link owner")
+		return
+	}
+
+	stats, err := h.clickTracker.LinkStats(r.Context(), shortURL)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to get link stats", zap.String("err", err.Error()))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "unable to get link stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to create response", zap.String("err", err.Error()))
+	}
 }
 
 func (h *Handler) createShortURLJson(w http.ResponseWriter, r *http.Request) {
@@ -85,34 +163,56 @@ func (h *Handler) createShortURLJson(w http.ResponseWriter, r *http.Request) {
 	var sr ShortenRequest
 	err := json.NewDecoder(r.Body).Decode(&sr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "request body must be a json object")
 		return
 	}
 
 	if isURLEmpty(sr.URL) {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidURL, "url must not be empty")
+		return
+	}
+	if err := shortener.ValidateOriginalURL(sr.URL); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidURL, err.Error())
+		return
+	}
+	expiresAt, err := shortener.ExpiresAt(sr.TTL, sr.ExpiresAt, time.Now())
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidExpiration, err.Error())
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
 
-	shortURL, err := h.urlShortener.Add(r.Context(), sr.URL)
+	userID, _ := auth.UserIDFromContext(r.Context())
+	shortURL, err := h.urlShortener.Add(r.Context(), models.URL{
+		ShortURL:    sr.Alias,
+		OriginalURL: sr.URL,
+		UserID:      userID,
+		ExpiresAt:   expiresAt,
+	})
+	if code, ok := aliasErrorCode(err); ok {
+		problem.Write(w, r, http.StatusConflict, code, err.Error())
+		return
+	}
 	if errors.Is(err, errs.ErrConflictOriginalURL) {
-		logger.Log.Info("original url already exist", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusConflict)
-	} else if err != nil {
-		logger.Log.Error("error to create short url", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Info("original url already exist", zap.String("err", err.Error()))
+		metrics.LinkConflict()
+		h.conflict(r, shortURL).Write(w)
 		return
-	} else {
-		w.WriteHeader(http.StatusCreated)
 	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to create short url", zap.String("err", err.Error()))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "unable to create short url")
+		return
+	}
+	metrics.LinkCreated()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 
 	response := ShortenerResponse{Result: h.prefixURL + shortURL}
 
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		logger.Log.Error("error to create response", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("error to create response", zap.String("err", err.Error()))
 	}
 }
 
@@ -122,27 +222,53 @@ func (h *Handler) createFromBatch(w http.ResponseWriter, r *http.Request) {
 	var urlBatch []ShortenRequestBatch
 	err := json.NewDecoder(r.Body).Decode(&urlBatch)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "request body must be a json array")
 		return
 	}
 
-	var originalURLs []string
-	for _, originalURL := range urlBatch {
-		originalURLs = append(originalURLs, originalURL.OriginalURL)
+	userID, _ := auth.UserIDFromContext(r.Context())
+	var urls []models.URL
+	for _, item := range urlBatch {
+		urls = append(urls, models.URL{
+			ShortURL:    item.Alias,
+			OriginalURL: item.OriginalURL,
+			UserID:      userID,
+		})
 	}
 
-	shortURLs, err := h.urlShortener.AddBatch(r.Context(), originalURLs)
+	results, err := h.urlShortener.AddBatch(r.Context(), urls)
+	if errors.Is(err, errs.ErrBatchTooLarge) {
+		problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeBatchTooLarge, err.Error())
+		return
+	}
+	if code, ok := aliasErrorCode(err); ok {
+		problem.Write(w, r, http.StatusConflict, code, err.Error())
+		return
+	}
 	if err != nil {
-		logger.Log.Error("error to create short url", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("error to create short url", zap.String("err", err.Error()))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "unable to create short urls")
 		return
 	}
-	var shortURLBatch []ShortenResponseBatch
-	for i, shortURL := range *shortURLs {
-		shortURLBatch = append(shortURLBatch, ShortenResponseBatch{
-			ShortURL:      h.prefixURL + shortURL,
+
+	shortURLBatch := make([]ShortenResponseBatch, 0, len(results))
+	for i, result := range results {
+		item := ShortenResponseBatch{
 			CorrelationID: urlBatch[i].CorrelationID,
-		})
+			Status:        string(result.Status),
+		}
+		switch result.Status {
+		case models.BatchCreated:
+			metrics.LinkCreated()
+			item.ShortURL = h.prefixURL + result.ShortURL
+		case models.BatchExisting:
+			metrics.LinkConflict()
+			item.ShortURL = h.prefixURL + result.ShortURL
+		case models.BatchInvalid:
+			item.Code = batchErrorCode(result.Err)
+			item.Error = result.Err.Error()
+		}
+		shortURLBatch = append(shortURLBatch, item)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -150,10 +276,127 @@ func (h *Handler) createFromBatch(w http.ResponseWriter, r *http.Request) {
 
 	err = json.NewEncoder(w).Encode(shortURLBatch)
 	if err != nil {
-		logger.Log.Error("error to create response", zap.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("error to create response", zap.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) getUserURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "user cookie is missing or invalid")
 		return
 	}
+
+	urls, err := h.urlShortener.GetByUserID(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to get user urls", zap.String("err", err.Error()))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "unable to get user urls")
+		return
+	}
+	if len(urls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	userURLs := make([]UserURLResponse, 0, len(urls))
+	for _, url := range urls {
+		userURLs = append(userURLs, UserURLResponse{
+			ShortURL:    h.prefixURL + url.ShortURL,
+			OriginalURL: url.OriginalURL,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(userURLs)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to create response", zap.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) deleteUserURLs(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "user cookie is missing or invalid")
+		return
+	}
+
+	var shortURLs []string
+	err := json.NewDecoder(r.Body).Decode(&shortURLs)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "request body must be a json array of short urls")
+		return
+	}
+
+	err = h.urlRemover.Remove(userID, shortURLs)
+	if err != nil {
+		logger.FromContext(r.Context()).Warn("error to queue urls for deletion", zap.String("err", err.Error()))
+		w.Header().Set("Retry-After", "1")
+		problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "deletion queue is full, retry later")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) getServiceStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.urlShortener.Stats(r.Context())
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to get service stats", zap.String("err", err.Error()))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "unable to get service stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to create response", zap.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) aliasAvailable(w http.ResponseWriter, r *http.Request) {
+	alias := chi.URLParam(r, "alias")
+
+	response := AliasAvailabilityResponse{Alias: alias, Available: true}
+	err := shortener.ValidateAlias(alias)
+	if err == nil {
+		// удалённые и истёкшие алиасы остаются занятыми
+		_, err = h.urlShortener.Get(r.Context(), alias)
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			err = nil
+		case err == nil || errors.Is(err, errs.ErrGone):
+			err = errs.ErrAliasTaken
+		default:
+			logger.FromContext(r.Context()).Error("error to get url", zap.String("err", err.Error()))
+			writeLookupError(w, r, err)
+			return
+		}
+	}
+	if code, ok := aliasErrorCode(err); ok {
+		response.Available = false
+		response.Reason = code
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("error to create response", zap.String("err", err.Error()))
+	}
+}
+
+// conflict описывает уже сокращённый адрес и несёт его существующую короткую ссылку
+func (h *Handler) conflict(r *http.Request, shortURL string) *problem.Problem {
+	p := problem.New(r, http.StatusConflict, problem.CodeConflict, errs.ErrConflictOriginalURL.Error())
+	p.Result = h.prefixURL + shortURL
+	return p
 }
 
 func isURLEmpty(url string) bool {
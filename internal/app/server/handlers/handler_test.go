@@ -3,16 +3,32 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clicks"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/utils"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func Test_createShortURL(t *testing.T) {
@@ -33,23 +49,32 @@ func Test_createShortURL(t *testing.T) {
 				contentType: "text/plain",
 			},
 			body:      strings.NewReader("https://ya.ru"),
-			shortener: shortener.NewFileURLMapper(5, "/tmp/short-url-db.json"),
+			shortener: shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json"),
 		},
 		{
 			name: "return status 400 for empty url",
 			want: want{
 				code:        http.StatusBadRequest,
-				contentType: "",
+				contentType: "text/plain; charset=utf-8",
 			},
 			body:      strings.NewReader(""),
-			shortener: shortener.NewFileURLMapper(5, "/tmp/short-url-db.json"),
+			shortener: shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json"),
+		},
+		{
+			name: "return status 400 for not absolute url",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+			},
+			body:      strings.NewReader("not a url"),
+			shortener: shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json"),
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/", test.body)
 			w := httptest.NewRecorder()
-			h := NewHandler(test.shortener, "http://localhost:80")
+			h := newTestHandler(t, test.shortener)
 
 			h.createShortURL(w, request)
 
@@ -67,7 +92,7 @@ func Test_createShortURL(t *testing.T) {
 }
 
 func Test_getURL(t *testing.T) {
-	urlMap, h := setUpSimple()
+	urlMap, h := setUpSimple(t)
 
 	for url, shortURL := range urlMap {
 		t.Run("positive, url: "+url, func(t *testing.T) {
@@ -99,13 +124,20 @@ func Test_getURL(t *testing.T) {
 	})
 }
 
-func setUpSimple() (map[string]string, *Handler) {
+func newTestHandler(t *testing.T, mapper URLShortener) *Handler {
+	clickStore, err := clicks.NewFileStore("")
+	require.NoError(t, err)
+	return NewHandler(mapper, shortener.NewURLRemover(mapper), clicks.NewRecorder(clickStore), "http://localhost:80")
+}
+
+func setUpSimple(t *testing.T) (map[string]string, *Handler) {
 	urlMap := make(map[string]string)
 	urls := []string{
 		"https://ya.ru",
 		"https://example.com",
 	}
-	h := NewHandler(shortener.NewFileURLMapper(5, "/tmp/short-url-db.json"), "http://localhost:80")
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, mapper)
 
 	for _, url := range urls {
 		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url))
@@ -120,6 +152,120 @@ func setUpSimple() (map[string]string, *Handler) {
 	return urlMap, h
 }
 
+func TestHandler_conflictProblem(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	existing, err := mapper.Add(context.Background(), models.URL{OriginalURL: "https://ya.ru"})
+	require.NoError(t, err)
+	h := newTestHandler(t, failingAddShortener{URLShortener: mapper, shortURL: existing})
+
+	t.Run("json", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://ya.ru"}`))
+		w := httptest.NewRecorder()
+		h.createShortURLJson(w, request)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		var response problem.Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, problem.CodeConflict, response.Code)
+		assert.Equal(t, "/api/shorten", response.Instance)
+		assert.Equal(t, "http://localhost:80/"+existing, response.Result)
+	})
+
+	t.Run("text/plain", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://ya.ru"))
+		w := httptest.NewRecorder()
+		h.createShortURL(w, request)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+		assert.Equal(t, "http://localhost:80/"+existing, w.Body.String())
+	})
+
+	t.Run("text endpoint asked for json", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://ya.ru"))
+		request.Header.Set("Accept", problem.ContentType)
+		w := httptest.NewRecorder()
+		h.createShortURL(w, request)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	})
+}
+
+func TestHandler_createFromBatch(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	existing, err := mapper.Add(context.Background(), models.URL{OriginalURL: "https://ya.ru"})
+	require.NoError(t, err)
+	h := newTestHandler(t, mapper)
+
+	body := `[
+		{"correlation_id":"1","original_url":"https://example.com"},
+		{"correlation_id":"2","original_url":"https://ya.ru"},
+		{"correlation_id":"3","original_url":"not a url"},
+		{"correlation_id":"4","original_url":"https://example.com"},
+		{"correlation_id":"5","original_url":"https://go.dev","alias":"api"}
+	]`
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.createFromBatch(w, request)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response []ShortenResponseBatch
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Len(t, response, 5)
+	for i, item := range response {
+		assert.Equal(t, strconv.Itoa(i+1), item.CorrelationID)
+	}
+
+	assert.Equal(t, "created", response[0].Status)
+	assert.Equal(t, ShortenResponseBatch{
+		ShortURL:      "http://localhost:80/" + existing,
+		CorrelationID: "2",
+		Status:        "existing",
+	}, response[1])
+	assert.Equal(t, "invalid", response[2].Status)
+	assert.Equal(t, problem.CodeInvalidURL, response[2].Code)
+	assert.Empty(t, response[2].ShortURL)
+	assert.Equal(t, "existing", response[3].Status)
+	assert.Equal(t, response[0].ShortURL, response[3].ShortURL)
+	assert.Equal(t, "invalid", response[4].Status)
+	assert.Equal(t, "reserved_alias", response[4].Code)
+}
+
+func TestHandler_createFromBatchTooLarge(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, newBatchLimitedShortener(mapper, 2))
+
+	body := `[
+		{"correlation_id":"1","original_url":"https://example.com"},
+		{"correlation_id":"2","original_url":"https://ya.ru"},
+		{"correlation_id":"3","original_url":"https://go.dev"}
+	]`
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.createFromBatch(w, request)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var response problem.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, problem.CodeBatchTooLarge, response.Code)
+
+	stats, err := mapper.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, stats.URLs)
+}
+
+// failingAddShortener отвечает на сохранение конфликтом с уже существующей ссылкой
+type failingAddShortener struct {
+	URLShortener
+	shortURL string
+}
+
+func (s failingAddShortener) Add(context.Context, models.URL) (string, error) {
+	return s.shortURL, errs.ErrConflictOriginalURL
+}
+
 func TestHandler_createShortURLJson(t *testing.T) {
 	type want struct {
 		code        int
@@ -138,23 +284,32 @@ func TestHandler_createShortURLJson(t *testing.T) {
 				contentType: "application/json",
 			},
 			body:      bytes.NewReader([]byte(`{"url":"https://yandex.ru"}`)),
-			shortener: shortener.NewFileURLMapper(5, "/tmp/short-url-db.json"),
+			shortener: shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json"),
 		},
 		{
 			name: "return status 400 for empty url",
 			want: want{
 				code:        http.StatusBadRequest,
-				contentType: "",
+				contentType: problem.ContentType,
 			},
 			body:      strings.NewReader(""),
-			shortener: shortener.NewFileURLMapper(5, "/tmp/short-url-db.json"),
+			shortener: shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json"),
+		},
+		{
+			name: "return status 400 for not http url",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: problem.ContentType,
+			},
+			body:      strings.NewReader(`{"url":"ftp://example.com/file"}`),
+			shortener: shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json"),
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/", test.body)
 			w := httptest.NewRecorder()
-			h := NewHandler(test.shortener, "http://localhost:80")
+			h := newTestHandler(t, test.shortener)
 
 			h.createShortURLJson(w, request)
 
@@ -170,3 +325,476 @@ func TestHandler_createShortURLJson(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_getUserURLs(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, mapper)
+	userID := auth.NewUserID()
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://practicum.yandex.ru"))
+	request = request.WithContext(auth.WithUserID(request.Context(), userID))
+	w := httptest.NewRecorder()
+	h.createShortURL(w, request)
+	res := w.Result()
+	res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	tests := []struct {
+		name   string
+		userID string
+		code   int
+	}{
+		{
+			name:   "owner gets own urls",
+			userID: userID,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "user without urls",
+			userID: auth.NewUserID(),
+			code:   http.StatusNoContent,
+		},
+		{
+			name:   "anonymous user",
+			userID: "",
+			code:   http.StatusUnauthorized,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			request = request.WithContext(auth.WithUserID(request.Context(), test.userID))
+			w := httptest.NewRecorder()
+
+			h.getUserURLs(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, test.code, res.StatusCode)
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+
+			var urls []UserURLResponse
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&urls))
+			require.Len(t, urls, 1)
+			assert.Equal(t, "https://practicum.yandex.ru", urls[0].OriginalURL)
+		})
+	}
+}
+
+func TestAuthenticator_Require(t *testing.T) {
+	a := auth.NewAuthenticator("secret")
+	userID := auth.NewUserID()
+	handler := a.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := auth.UserIDFromContext(r.Context())
+		assert.Equal(t, userID, id)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		cookie string
+		code   int
+	}{
+		{name: "valid cookie", cookie: a.Sign(userID), code: http.StatusOK},
+		{name: "forged cookie", cookie: auth.NewAuthenticator("other").Sign(userID), code: http.StatusUnauthorized},
+		{name: "broken cookie", cookie: userID, code: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			request.AddCookie(&http.Cookie{Name: auth.CookieName, Value: test.cookie})
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request)
+
+			res := w.Result()
+			res.Body.Close()
+			assert.Equal(t, test.code, res.StatusCode)
+		})
+	}
+}
+
+func TestHandler_deleteUserURLs(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	remover := shortener.NewURLRemover(mapper)
+	clickStore, err := clicks.NewFileStore("")
+	require.NoError(t, err)
+	h := NewHandler(mapper, remover, clicks.NewRecorder(clickStore), "http://localhost:80")
+	ctx := context.Background()
+
+	shortURL, err := mapper.Add(ctx, models.URL{OriginalURL: "https://deleted.example.com", UserID: "owner"})
+	require.NoError(t, err)
+	foreignShortURL, err := mapper.Add(ctx, models.URL{OriginalURL: "https://foreign.example.com", UserID: "stranger"})
+	require.NoError(t, err)
+
+	body, _ := json.Marshal([]string{shortURL, foreignShortURL})
+	request := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewReader(body))
+	request = request.WithContext(auth.WithUserID(request.Context(), "owner"))
+	w := httptest.NewRecorder()
+
+	h.deleteUserURLs(w, request)
+
+	res := w.Result()
+	res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	remover.Close()
+
+	for code, status := range map[string]int{
+		shortURL:        http.StatusGone,
+		foreignShortURL: http.StatusTemporaryRedirect,
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/{id}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", code)
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		h.getURL(w, request)
+
+		res := w.Result()
+		res.Body.Close()
+		assert.Equal(t, status, res.StatusCode)
+	}
+}
+
+// busyRemover отвечает так же, как URLRemover с заполненной очередью
+type busyRemover struct{}
+
+func (busyRemover) Remove(string, []string) error {
+	return errs.ErrRemoverBusy
+}
+
+func TestHandler_deleteUserURLsBusy(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), "/tmp/short-url-db.json")
+	clickStore, err := clicks.NewFileStore("")
+	require.NoError(t, err)
+	h := NewHandler(mapper, busyRemover{}, clicks.NewRecorder(clickStore), "http://localhost:80")
+
+	request := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abcde"]`))
+	request = request.WithContext(auth.WithUserID(request.Context(), "owner"))
+	w := httptest.NewRecorder()
+	h.deleteUserURLs(w, request)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestHandler_createShortURLJsonAlias(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, mapper)
+
+	tests := []struct {
+		name  string
+		body  string
+		code  int
+		error string
+	}{
+		{
+			name: "alias reserved",
+			body: `{"url":"https://ya.ru","alias":"my-link"}`,
+			code: http.StatusCreated,
+		},
+		{
+			name:  "alias already taken",
+			body:  `{"url":"https://example.com","alias":"my-link"}`,
+			code:  http.StatusConflict,
+			error: "alias_taken",
+		},
+		{
+			name:  "reserved word",
+			body:  `{"url":"https://example.com","alias":"API"}`,
+			code:  http.StatusConflict,
+			error: "reserved_alias",
+		},
+		{
+			name:  "invalid characters",
+			body:  `{"url":"https://example.com","alias":"my link"}`,
+			code:  http.StatusConflict,
+			error: "invalid_alias",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+
+			h.createShortURLJson(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, test.code, res.StatusCode)
+			if test.error != "" {
+				var response problem.Problem
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+				assert.Equal(t, test.error, response.Code)
+				return
+			}
+			var response ShortenerResponse
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+			assert.Equal(t, "http://localhost:80/my-link", response.Result)
+		})
+	}
+
+	for alias, available := range map[string]bool{"my-link": false, "free-link": true, "ping": false} {
+		t.Run("availability of "+alias, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/aliases/{alias}/available", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", alias)
+			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			h.aliasAvailable(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			var response AliasAvailabilityResponse
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+			assert.Equal(t, available, response.Available)
+		})
+	}
+}
+
+func TestHandler_expiringURL(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, mapper)
+
+	for body, code := range map[string]int{
+		`{"url":"https://ya.ru","ttl":"-1h"}`:                                    http.StatusBadRequest,
+		`{"url":"https://ya.ru","expires_at":"2000-01-01T00:00:00Z"}`:            http.StatusBadRequest,
+		`{"url":"https://ya.ru","ttl":"1h","expires_at":"2100-01-01T00:00:00Z"}`: http.StatusBadRequest,
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.createShortURLJson(w, request)
+		res := w.Result()
+		res.Body.Close()
+		assert.Equal(t, code, res.StatusCode, body)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://ya.ru","ttl":"1ms"}`))
+	w := httptest.NewRecorder()
+	h.createShortURLJson(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var response ShortenerResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+	shortURL := strings.TrimPrefix(response.Result, "http://localhost:80/")
+
+	time.Sleep(5 * time.Millisecond)
+
+	getStatus := func() int {
+		request := httptest.NewRequest(http.MethodGet, "/{id}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", shortURL)
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		h.getURL(w, request)
+		res := w.Result()
+		res.Body.Close()
+		return res.StatusCode
+	}
+	assert.Equal(t, http.StatusGone, getStatus())
+
+	purged, err := mapper.PurgeExpired(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	// код вычищенной ссылки не освобождается, она так и остаётся ушедшей
+	assert.Equal(t, http.StatusGone, getStatus())
+}
+
+// failingShortener отвечает на чтение заданной ошибкой
+type failingShortener struct {
+	URLShortener
+	err error
+}
+
+func (s failingShortener) Get(context.Context, string) (models.URL, error) {
+	return models.URL{OriginalURL: "https://ya.ru"}, s.err
+}
+
+func TestHandler_getURLErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{err: errs.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+		{err: errs.ErrGone, status: http.StatusGone, code: "gone"},
+		{err: fmt.Errorf("%w: connection refused", errs.ErrUnavailable), status: http.StatusServiceUnavailable, code: "unavailable"},
+		{err: errors.New("unexpected"), status: http.StatusServiceUnavailable, code: "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			h := newTestHandler(t, failingShortener{err: tt.err})
+
+			request := httptest.NewRequest(http.MethodGet, "/{id}", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "abcde")
+			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			h.getURL(w, request)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Empty(t, w.Header().Get("Location"))
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+			var response problem.Problem
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Equal(t, tt.code, response.Code)
+			assert.Equal(t, tt.status, response.Status)
+			assert.NotContains(t, response.Detail, "connection refused")
+		})
+	}
+}
+
+func TestHandler_getLinkStats(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	clickStore, err := clicks.NewFileStore(t.TempDir() + "/db.json.clicks")
+	require.NoError(t, err)
+	recorder := clicks.NewRecorder(clickStore)
+	h := NewHandler(mapper, shortener.NewURLRemover(mapper), recorder, "http://localhost:80")
+
+	shortURL, err := mapper.Add(context.Background(), models.URL{OriginalURL: "https://ya.ru", UserID: "owner"})
+	require.NoError(t, err)
+
+	for _, userAgent := range []string{"firefox", "chrome", "chrome"} {
+		request := httptest.NewRequest(http.MethodGet, "/{id}", nil)
+		request.Header.Set("User-Agent", userAgent)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", shortURL)
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		h.getURL(w, request)
+		res := w.Result()
+		res.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+	}
+	recorder.Close()
+
+	tests := []struct {
+		id     string
+		userID string
+		code   int
+	}{
+		{id: shortURL, userID: "owner", code: http.StatusOK},
+		{id: shortURL, userID: "stranger", code: http.StatusForbidden},
+		{id: shortURL, code: http.StatusUnauthorized},
+		{id: "unknown", userID: "owner", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		id, code := tt.id, tt.code
+		request := httptest.NewRequest(http.MethodGet, "/api/links/{id}/stats", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(request.Context(), chi.RouteCtxKey, rctx)
+		if tt.userID != "" {
+			ctx = auth.WithUserID(ctx, tt.userID)
+		}
+		request = request.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		h.getLinkStats(w, request)
+
+		res := w.Result()
+		assert.Equal(t, code, res.StatusCode, tt.userID)
+		if code == http.StatusOK {
+			var stats models.LinkStats
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&stats))
+			assert.Equal(t, int64(3), stats.TotalClicks)
+			assert.Equal(t, int64(2), stats.UniqueVisitors)
+			require.Len(t, stats.Days, 1)
+			assert.Equal(t, int64(3), stats.Days[0].Clicks)
+		}
+		res.Body.Close()
+	}
+}
+
+func TestHandler_getServiceStats(t *testing.T) {
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, mapper)
+	for _, url := range []models.URL{
+		{OriginalURL: "https://ya.ru", UserID: "first"},
+		{OriginalURL: "https://example.com", UserID: "first"},
+		{OriginalURL: "https://go.dev", UserID: "second"},
+	} {
+		_, err := mapper.Add(context.Background(), url)
+		require.NoError(t, err)
+	}
+
+	resolver, err := clientip.NewResolver("10.0.0.1")
+	require.NoError(t, err)
+	trustedSubnet, err := clientip.ParseSubnet("192.168.1.0/24")
+	require.NoError(t, err)
+	handler := resolver.Middleware(trustedSubnet.Middleware(http.HandlerFunc(h.getServiceStats)))
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		code    int
+	}{
+		{name: "direct client from subnet", peer: "192.168.1.5:1234", code: http.StatusOK},
+		{name: "direct client outside subnet", peer: "8.8.8.8:1234", code: http.StatusForbidden},
+		{
+			name:    "spoofed header from untrusted peer",
+			peer:    "8.8.8.8:1234",
+			headers: map[string]string{"X-Real-IP": "192.168.1.5"},
+			code:    http.StatusForbidden,
+		},
+		{
+			name:    "real ip from trusted proxy",
+			peer:    "10.0.0.1:1234",
+			headers: map[string]string{"X-Real-IP": "192.168.1.5"},
+			code:    http.StatusOK,
+		},
+		{
+			name:    "forwarded chain ends outside subnet",
+			peer:    "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "192.168.1.5, 8.8.8.8"},
+			code:    http.StatusForbidden,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			request.RemoteAddr = test.peer
+			for key, value := range test.headers {
+				request.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, test.code, res.StatusCode)
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+			var stats models.ServiceStats
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&stats))
+			assert.Equal(t, models.ServiceStats{URLs: 3, Users: 2}, stats)
+		})
+	}
+}
+
+func TestTracedShortener_spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	mapper := shortener.NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), t.TempDir()+"/db.json")
+	h := newTestHandler(t, newTracedShortener(mapper))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url": "https://ya.ru"}`))
+	w := httptest.NewRecorder()
+	h.createShortURLJson(w, request)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "URLShortener.Add", spans[0].Name())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/metrics"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// instrumentedShortener замеряет длительность методов хранилища ссылок
+type instrumentedShortener struct {
+	next    URLShortener
+	storage string
+}
+
+func newInstrumentedShortener(next URLShortener, storage string) URLShortener {
+	return &instrumentedShortener{next: next, storage: storage}
+}
+
+func (s *instrumentedShortener) Add(ctx context.Context, url models.URL) (shortURL string, err error) {
+	defer func(start time.Time) { s.observe("Add", start, err) }(time.Now())
+	return s.next.Add(ctx, url)
+}
+
+func (s *instrumentedShortener) AddBatch(ctx context.Context, urls []models.URL) (results []models.BatchResult, err error) {
+	defer func(start time.Time) { s.observe("AddBatch", start, err) }(time.Now())
+	return s.next.AddBatch(ctx, urls)
+}
+
+func (s *instrumentedShortener) Get(ctx context.Context, shortURL string) (url models.URL, err error) {
+	defer func(start time.Time) { s.observe("Get", start, err) }(time.Now())
+	return s.next.Get(ctx, shortURL)
+}
+
+func (s *instrumentedShortener) GetByUserID(ctx context.Context, userID string) (urls []models.URL, err error) {
+	defer func(start time.Time) { s.observe("GetByUserID", start, err) }(time.Now())
+	return s.next.GetByUserID(ctx, userID)
+}
+
+func (s *instrumentedShortener) Delete(ctx context.Context, userID string, shortURLs []string) (err error) {
+	defer func(start time.Time) { s.observe("Delete", start, err) }(time.Now())
+	return s.next.Delete(ctx, userID, shortURLs)
+}
+
+func (s *instrumentedShortener) PurgeExpired(ctx context.Context, now time.Time) (n int64, err error) {
+	defer func(start time.Time) { s.observe("PurgeExpired", start, err) }(time.Now())
+	return s.next.PurgeExpired(ctx, now)
+}
+
+func (s *instrumentedShortener) Stats(ctx context.Context) (stats *models.ServiceStats, err error) {
+	defer func(start time.Time) { s.observe("Stats", start, err) }(time.Now())
+	return s.next.Stats(ctx)
+}
+
+func (s *instrumentedShortener) Close() error {
+	return s.next.Close()
+}
+
+// observe не считает ошибками конфликты и ненайденные ссылки, о которых сообщается клиенту
+func (s *instrumentedShortener) observe(method string, start time.Time, err error) {
+	if _, ok := aliasErrorCode(err); ok || isExpectedLookupError(err) || errors.Is(err, errs.ErrConflictOriginalURL) {
+		err = nil
+	}
+	metrics.ObserveStorage(s.storage, method, start, err)
+}
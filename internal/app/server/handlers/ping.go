@@ -1,8 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+	"go.uber.org/zap"
 	"net/http"
 )
 
@@ -16,13 +18,12 @@ func NewPingHandler(pingService service.PingService) *PingHandler {
 
 func (h *PingHandler) healthDB(w http.ResponseWriter, r *http.Request) {
 	err := h.pingService.PingDB(r.Context())
-	w.Header().Set("Content-Type", "application/json")
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		message, _ := json.Marshal(map[string]string{"name": err.Error()})
-		http.Error(w, string(message), http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error("error to ping db", zap.String("err", err.Error()))
+		problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "database is unavailable")
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte("ok"))
 }
@@ -1,28 +1,182 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
 	"github.com/go-chi/chi/v5"
 
 	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clicks"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/ratelimit"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/filelog"
 )
 
-func RegisterHTTPEndpoint(router *chi.Mux, services *service.Services, cfg *config.Config) error {
+// NewURLShortener создаёт хранилище ссылок, выбранное cfg.StorageBackend.
+// Чтение по коду идёт через кеш, если он не отключён, размер пачек ограничен cfg.BatchMaxSize.
+func NewURLShortener(services *service.Services, cfg *config.Config) (URLShortener, error) {
+	cacheOptions := CacheOptions{
+		Size:        cfg.CacheSize,
+		TTL:         cfg.CacheTTL,
+		NegativeTTL: cfg.CacheNegativeTTL,
+	}
+
+	codeOptions := codegen.Options{
+		Strategy: cfg.CodeStrategy,
+		Alphabet: cfg.CodeAlphabet,
+		Length:   cfg.CodeLength,
+		Salt:     cfg.CodeSalt,
+	}
+
+	backend := cfg.StorageBackend()
+	if backend == config.StoragePostgres {
+		generator, err := codegen.New(codeOptions, services.SequenceService)
+		if err != nil {
+			return nil, err
+		}
+		mapper := shortener.NewDBUrlMapper(generator, services.URLService)
+		return newTracedShortener(newBatchLimitedShortener(newCachedShortener(mapper, cacheOptions), cfg.BatchMaxSize)), nil
+	}
+
+	counter, err := codegen.NewFileCounter(siblingPath(storagePath(cfg), ".seq"))
+	if err != nil {
+		return nil, err
+	}
+	generator, err := codegen.New(codeOptions, counter)
+	if err != nil {
+		return nil, err
+	}
 	var mapper URLShortener
+	if backend == config.StorageFile {
+		mapper, err = shortener.OpenFileURLMapper(generator, cfg.FileStoragePath, shortener.FileOptions{
+			Sync:         filelog.SyncPolicy(cfg.FileSync),
+			SyncInterval: cfg.FileSyncInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		mapper = shortener.NewMemoryURLMapper(generator)
+	}
+	mapper = newCachedShortener(newInstrumentedShortener(mapper, backend), cacheOptions)
+	return newTracedShortener(newBatchLimitedShortener(mapper, cfg.BatchMaxSize)), nil
+}
+
+// NewClickStore выбирает хранилище статистики переходов под то же хранилище, что и ссылки
+func NewClickStore(services *service.Services, cfg *config.Config) (clicks.Store, error) {
+	if cfg.StorageBackend() == config.StoragePostgres {
+		return services.ClickService, nil
+	}
+	return clicks.NewFileStore(siblingPath(storagePath(cfg), ".clicks"))
+}
+
+func RegisterHTTPEndpoint(
+	router *chi.Mux,
+	mapper URLShortener,
+	remover URLRemover,
+	clickTracker ClickTracker,
+	authenticator *auth.Authenticator,
+	services *service.Services,
+	cfg *config.Config,
+) error {
+	resolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	trustedSubnet, err := clientip.ParseSubnet(cfg.TrustedSubnet)
+	if err != nil {
+		return err
+	}
+	router.Use(resolver.Middleware)
+
+	router.Use(authenticator.Issue)
+
+	limitStore := NewRateLimitStore(services, cfg)
+	limitKey := rateLimitKey(authenticator, cfg.APIKeys)
+	writeLimit := ratelimit.NewLimiter(
+		"write",
+		limitStore,
+		ratelimit.Limit{Rate: cfg.WriteRateLimit, Burst: cfg.WriteRateBurst},
+		limitKey,
+	)
+	redirectLimit := ratelimit.NewLimiter(
+		"redirect",
+		limitStore,
+		ratelimit.Limit{Rate: cfg.RedirectRateLimit, Burst: cfg.RedirectRateBurst},
+		limitKey,
+	)
+
+	// chi запрещает добавлять middleware после первого маршрута, поэтому маршруты объявляются только здесь
 	if cfg.DatabaseDSN != "" {
 		pingHandler := NewPingHandler(services.PingService)
 		router.Get("/ping", pingHandler.healthDB)
-		mapper = shortener.NewDBUrlMapper(5, services.URLService)
-	} else {
-		mapper = shortener.NewFileURLMapper(5, cfg.FileStoragePath)
 	}
 
-	h := NewHandler(mapper, cfg.PrefixURL)
-	router.Get("/{id}", h.getURL)
-	router.Post("/", h.createShortURL)
-	router.Post("/api/shorten", h.createShortURLJson)
-	router.Post("/api/shorten/batch", h.createFromBatch)
+	h := NewHandler(mapper, remover, clickTracker, cfg.PrefixURL)
+	router.With(redirectLimit.Middleware).Get("/{id}", h.getURL)
+	router.With(writeLimit.Middleware).Post("/", h.createShortURL)
+	router.With(writeLimit.Middleware).Post("/api/shorten", h.createShortURLJson)
+	router.With(writeLimit.Middleware).Post("/api/shorten/batch", h.createFromBatch)
+	router.Get("/api/aliases/{alias}/available", h.aliasAvailable)
+	router.With(authenticator.Require).Get("/api/links/{id}/stats", h.getLinkStats)
+	router.With(authenticator.Require).Get("/api/user/urls", h.getUserURLs)
+	router.With(authenticator.Require, writeLimit.Middleware).Delete("/api/user/urls", h.deleteUserURLs)
+	router.With(trustedSubnet.Middleware).Get("/api/internal/stats", h.getServiceStats)
 
 	return nil
 }
+
+// NewRateLimitStore выбирает хранилище вёдер лимитов: общее в postgres или в памяти процесса
+func NewRateLimitStore(services *service.Services, cfg *config.Config) ratelimit.Store {
+	if cfg.RateLimitStore == config.RateLimitStorePostgres && services != nil {
+		return services.RateLimitService
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// rateLimitKey выбирает, чей лимит расходует запрос: известного API ключа,
+// пользователя с валидной кукой или, если их нет, адреса клиента
+func rateLimitKey(authenticator *auth.Authenticator, apiKeys string) ratelimit.KeyFunc {
+	known := make(map[string]struct{})
+	for _, key := range strings.Split(apiKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			known[key] = struct{}{}
+		}
+	}
+
+	return func(r *http.Request) string {
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			if _, ok := known[key]; ok {
+				sum := sha256.Sum256([]byte(key))
+				return "key:" + hex.EncodeToString(sum[:8])
+			}
+		}
+		if userID, ok := authenticator.UserIDFromRequest(r); ok {
+			return "user:" + userID
+		}
+		return "ip:" + clientIP(r)
+	}
+}
+
+// storagePath возвращает путь файлового хранилища или пустую строку, если данные живут в памяти
+func storagePath(cfg *config.Config) string {
+	if cfg.StorageBackend() != config.StorageFile {
+		return ""
+	}
+	return cfg.FileStoragePath
+}
+
+// siblingPath возвращает путь вспомогательного файла рядом с файловым хранилищем
+func siblingPath(fileStoragePath string, suffix string) string {
+	if fileStoragePath == "" {
+		return ""
+	}
+	return fileStoragePath + suffix
+}
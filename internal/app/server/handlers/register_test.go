@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/config"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/auth"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clicks"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+)
+
+// stubPingService отвечает на ping заданной ошибкой
+type stubPingService struct {
+	err error
+}
+
+func (s stubPingService) PingDB(context.Context) error {
+	return s.err
+}
+
+func newTestRouter(t *testing.T, services *service.Services, cfg *config.Config) *chi.Mux {
+	mapper := shortener.NewMemoryURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 5))
+	clickStore, err := clicks.NewFileStore("")
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	err = RegisterHTTPEndpoint(
+		router,
+		mapper,
+		shortener.NewURLRemover(mapper),
+		clicks.NewRecorder(clickStore),
+		auth.NewAuthenticator("secret"),
+		services,
+		cfg,
+	)
+	require.NoError(t, err)
+	return router
+}
+
+func TestRegisterHTTPEndpoint_ping(t *testing.T) {
+	cfg := &config.Config{PrefixURL: "http://localhost:80", DatabaseDSN: "postgres://localhost/shortener"}
+	services := &service.Services{PingService: stubPingService{}}
+
+	var router *chi.Mux
+	require.NotPanics(t, func() {
+		router = newTestRouter(t, services, cfg)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestRegisterHTTPEndpoint_pingUnavailable(t *testing.T) {
+	cfg := &config.Config{PrefixURL: "http://localhost:80", DatabaseDSN: "postgres://localhost/shortener"}
+	services := &service.Services{PingService: stubPingService{err: errors.New("connection refused")}}
+	router := newTestRouter(t, services, cfg)
+
+	request := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), problem.CodeUnavailable)
+}
+
+// Без куки запросы делят лимит адреса клиента, а пользователь с валидной кукой
+// и известный API ключ получают свои
+func TestRegisterHTTPEndpoint_rateLimitKey(t *testing.T) {
+	cfg := &config.Config{
+		PrefixURL:      "http://localhost:80",
+		WriteRateLimit: 0.001,
+		WriteRateBurst: 2,
+		APIKeys:        "partner-key",
+	}
+	router := newTestRouter(t, nil, cfg)
+	authenticator := auth.NewAuthenticator("secret")
+
+	shorten := func(url string, prepare func(r *http.Request)) int {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url))
+		prepare(request)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Code
+	}
+	withoutCookie := func(*http.Request) {}
+	userCookie := authenticator.Sign(auth.NewUserID())
+	withUserCookie := func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: auth.CookieName, Value: userCookie})
+	}
+	withForgedCookie := func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: auth.CookieName, Value: auth.NewAuthenticator("other").Sign(auth.NewUserID())})
+	}
+	withAPIKey := func(r *http.Request) {
+		r.Header.Set("X-API-Key", "partner-key")
+	}
+
+	// каждый такой запрос получает новую куку, но не предъявляет её и расходует лимит адреса
+	assert.Equal(t, http.StatusCreated, shorten("https://first.example.com", withoutCookie))
+	assert.Equal(t, http.StatusCreated, shorten("https://second.example.com", withoutCookie))
+	assert.Equal(t, http.StatusTooManyRequests, shorten("https://third.example.com", withoutCookie))
+	assert.Equal(t, http.StatusTooManyRequests, shorten("https://third.example.com", withForgedCookie))
+
+	assert.Equal(t, http.StatusCreated, shorten("https://third.example.com", withUserCookie))
+	assert.Equal(t, http.StatusCreated, shorten("https://fourth.example.com", withUserCookie))
+	assert.Equal(t, http.StatusTooManyRequests, shorten("https://fifth.example.com", withUserCookie))
+
+	assert.Equal(t, http.StatusCreated, shorten("https://fifth.example.com", withAPIKey))
+}
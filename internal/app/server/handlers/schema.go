@@ -1,7 +1,13 @@
 package handlers
 
+import "time"
+
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL   string `json:"url"`
+	Alias string `json:"alias,omitempty"`
+	// TTL - срок жизни ссылки в формате time.ParseDuration, например "24h"
+	TTL       string     `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 type ShortenerResponse struct {
@@ -11,9 +17,26 @@ type ShortenerResponse struct {
 type ShortenRequestBatch struct {
 	OriginalURL   string `json:"original_url"`
 	CorrelationID string `json:"correlation_id"`
+	Alias         string `json:"alias,omitempty"`
 }
 
 type ShortenResponseBatch struct {
-	ShortURL      string `json:"short_url"`
+	ShortURL      string `json:"short_url,omitempty"`
 	CorrelationID string `json:"correlation_id"`
+	// Status - created, existing или invalid
+	Status string `json:"status"`
+	// Code и Error объясняют, почему элемент invalid
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type UserURLResponse struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+type AliasAvailabilityResponse struct {
+	Alias     string `json:"alias"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
 }
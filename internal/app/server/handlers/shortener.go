@@ -2,10 +2,32 @@ package handlers
 
 import (
 	"context"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
 )
 
 type URLShortener interface {
-	Add(ctx context.Context, url string) (string, error)
-	AddBatch(ctx context.Context, url []string) (*[]string, error)
-	Get(ctx context.Context, shortURL string) (string, bool)
+	Add(ctx context.Context, url models.URL) (string, error)
+	// AddBatch возвращает результат по каждому элементу в порядке urls. Невалидные
+	// элементы получают models.BatchInvalid, не прерывая сохранение остальных.
+	AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error)
+	// Get возвращает errs.ErrNotFound для неизвестного кода, errs.ErrGone вместе со ссылкой
+	// для удалённой или истёкшей и errs.ErrUnavailable, если хранилище не ответило
+	Get(ctx context.Context, shortURL string) (models.URL, error)
+	GetByUserID(ctx context.Context, userID string) ([]models.URL, error)
+	Delete(ctx context.Context, userID string, shortURLs []string) error
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+	Stats(ctx context.Context) (*models.ServiceStats, error)
+	// Close дожидается записи принятых изменений и освобождает хранилище
+	Close() error
+}
+
+type URLRemover interface {
+	Remove(userID string, shortURLs []string) error
+}
+
+type ClickTracker interface {
+	Record(click models.Click)
+	LinkStats(ctx context.Context, shortURL string) (*models.LinkStats, error)
 }
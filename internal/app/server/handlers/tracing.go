@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/tracing"
+)
+
+// tracedShortener открывает спаны на сохранение и чтение ссылок, остальные методы проходят насквозь
+type tracedShortener struct {
+	URLShortener
+}
+
+func newTracedShortener(next URLShortener) URLShortener {
+	return &tracedShortener{URLShortener: next}
+}
+
+func (s *tracedShortener) Add(ctx context.Context, url models.URL) (string, error) {
+	ctx, span := tracing.Start(ctx, "URLShortener.Add", attribute.Bool("shortener.alias", url.ShortURL != ""))
+	shortURL, err := s.URLShortener.Add(ctx, url)
+	spanErr := err
+	if errors.Is(err, errs.ErrConflictOriginalURL) {
+		span.SetAttributes(attribute.Bool("shortener.conflict", true))
+		spanErr = nil
+	}
+	tracing.End(span, spanErr)
+	return shortURL, err
+}
+
+func (s *tracedShortener) AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error) {
+	ctx, span := tracing.Start(ctx, "URLShortener.AddBatch", attribute.Int("shortener.batch_size", len(urls)))
+	results, err := s.URLShortener.AddBatch(ctx, urls)
+	statuses := make(map[models.BatchStatus]int)
+	for _, result := range results {
+		statuses[result.Status]++
+	}
+	span.SetAttributes(
+		attribute.Int("shortener.batch_created", statuses[models.BatchCreated]),
+		attribute.Int("shortener.batch_existing", statuses[models.BatchExisting]),
+		attribute.Int("shortener.batch_invalid", statuses[models.BatchInvalid]),
+	)
+	tracing.End(span, err)
+	return results, err
+}
+
+func (s *tracedShortener) Get(ctx context.Context, shortURL string) (models.URL, error) {
+	ctx, span := tracing.Start(ctx, "URLShortener.Get", attribute.String("shortener.code", shortURL))
+	url, err := s.URLShortener.Get(ctx, shortURL)
+	span.SetAttributes(attribute.Bool("shortener.found", !errors.Is(err, errs.ErrNotFound)))
+	spanErr := err
+	if isExpectedLookupError(err) {
+		spanErr = nil
+	}
+	tracing.End(span, spanErr)
+	return url, err
+}
@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"io"
 	"log"
+	"net"
+	"net/http"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/clientip"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/problem"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
 )
 
 func readBody(reqBody io.ReadCloser) (string, error) {
@@ -14,3 +21,62 @@ func readBody(reqBody io.ReadCloser) (string, error) {
 
 	return string(body), nil
 }
+
+// aliasErrorCode возвращает машиночитаемый код ошибки резервирования алиаса
+func aliasErrorCode(err error) (string, bool) {
+	switch {
+	case errors.Is(err, errs.ErrInvalidAlias):
+		return problem.CodeInvalidAlias, true
+	case errors.Is(err, errs.ErrReservedAlias):
+		return problem.CodeReservedAlias, true
+	case errors.Is(err, errs.ErrAliasTaken):
+		return problem.CodeAliasTaken, true
+	default:
+		return "", false
+	}
+}
+
+// batchErrorCode возвращает машиночитаемый код причины, по которой элемент пачки не сохранён
+func batchErrorCode(err error) string {
+	if code, ok := aliasErrorCode(err); ok {
+		return code
+	}
+	return problem.CodeInvalidURL
+}
+
+// isExpectedLookupError отделяет ответы о ненайденной или удалённой ссылке от сбоев хранилища
+func isExpectedLookupError(err error) bool {
+	return errors.Is(err, errs.ErrNotFound) || errors.Is(err, errs.ErrGone)
+}
+
+// writeLookupError отвечает на ошибку URLShortener.Get: 404, 410 или 503
+func writeLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, errs.ErrNotFound.Error())
+	case errors.Is(err, errs.ErrGone):
+		problem.Write(w, r, http.StatusGone, problem.CodeGone, errs.ErrGone.Error())
+	default:
+		problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, errs.ErrUnavailable.Error())
+	}
+}
+
+// writeTextError отвечает клиентам POST / простым текстом, если они не просят JSON
+func writeTextError(w http.ResponseWriter, r *http.Request, p *problem.Problem) {
+	if problem.AcceptsJSON(r) {
+		p.Write(w)
+		return
+	}
+	http.Error(w, p.Detail, p.Status)
+}
+
+func clientIP(r *http.Request) string {
+	if ip, ok := clientip.FromContext(r.Context()); ok {
+		return ip.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
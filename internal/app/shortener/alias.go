@@ -0,0 +1,38 @@
+package shortener
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+)
+
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{2,63}$`)
+
+// ValidateAlias проверяет, что алиас можно использовать как короткий код
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errs.ErrInvalidAlias
+	}
+	if codegen.IsReserved(alias) {
+		return errs.ErrReservedAlias
+	}
+	return nil
+}
+
+// reserveAlias пытается занять ровно указанный код, без перегенерации
+func reserveAlias(alias string, reserve func(code string) error) (string, error) {
+	err := ValidateAlias(alias)
+	if err != nil {
+		return "", err
+	}
+	err = reserve(alias)
+	if errors.Is(err, codegen.ErrCollision) {
+		return "", errs.ErrAliasTaken
+	}
+	if err != nil {
+		return "", err
+	}
+	return alias, nil
+}
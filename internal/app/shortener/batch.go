@@ -0,0 +1,91 @@
+package shortener
+
+import (
+	"net/url"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// ValidateOriginalURL проверяет, что адрес можно сократить
+func ValidateOriginalURL(originalURL string) error {
+	u, err := url.Parse(originalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errs.ErrInvalidURL
+	}
+	return nil
+}
+
+// planBatch разбирает пачку до записи. Невалидные элементы сразу получают BatchInvalid
+// и не мешают остальным, повторы адреса внутри пачки сохраняются один раз.
+// Возвращает результаты по всем элементам и индексы тех, что нужно сохранить.
+func planBatch(urls []models.URL, exists func(code string) (bool, error)) ([]models.BatchResult, []int, error) {
+	results := make([]models.BatchResult, len(urls))
+	var pending []int
+	originals := make(map[string]struct{})
+	aliases := make(map[string]struct{})
+	for i, url := range urls {
+		err := ValidateOriginalURL(url.OriginalURL)
+		if err != nil {
+			results[i] = invalidResult(err)
+			continue
+		}
+		if _, ok := originals[url.OriginalURL]; ok {
+			continue
+		}
+		if url.ShortURL != "" {
+			err = ValidateAlias(url.ShortURL)
+			if err != nil {
+				results[i] = invalidResult(err)
+				continue
+			}
+			_, taken := aliases[url.ShortURL]
+			if !taken {
+				taken, err = exists(url.ShortURL)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			if taken {
+				results[i] = invalidResult(errs.ErrAliasTaken)
+				continue
+			}
+			aliases[url.ShortURL] = struct{}{}
+		}
+		originals[url.OriginalURL] = struct{}{}
+		pending = append(pending, i)
+	}
+	return results, pending, nil
+}
+
+// pendingArgs собирает аргументы codegen.Reserver.ReserveBatch для сохраняемых элементов
+func pendingArgs(urls []models.URL, pending []int) (originalURLs []string, aliases []string) {
+	originalURLs = make([]string, len(pending))
+	aliases = make([]string, len(pending))
+	for j, i := range pending {
+		originalURLs[j] = urls[i].OriginalURL
+		aliases[j] = urls[i].ShortURL
+	}
+	return originalURLs, aliases
+}
+
+// resolveDuplicates отдаёт повторам адреса внутри пачки результат его сохранённого вхождения
+func resolveDuplicates(urls []models.URL, pending []int, results []models.BatchResult) {
+	saved := make(map[string]models.BatchResult, len(pending))
+	for _, i := range pending {
+		saved[urls[i].OriginalURL] = results[i]
+	}
+	for i, url := range urls {
+		if results[i].Status != "" {
+			continue
+		}
+		results[i] = saved[url.OriginalURL]
+		if results[i].Status == models.BatchCreated {
+			results[i].Status = models.BatchExisting
+		}
+	}
+}
+
+func invalidResult(err error) models.BatchResult {
+	return models.BatchResult{Status: models.BatchInvalid, Err: err}
+}
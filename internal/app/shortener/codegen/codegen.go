@@ -0,0 +1,99 @@
+package codegen
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	StrategyRandom   = "random"
+	StrategySequence = "sequence"
+	StrategyHash     = "hash"
+	StrategySqids    = "sqids"
+)
+
+const Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// MaxLength - предельная длина кода, как и у алиаса. Заодно укладывается в MinLength у sqids (uint8).
+const MaxLength = 64
+
+// CodeGenerator предлагает кандидата в короткий код для ссылки.
+// attempt - номер попытки, растёт при коллизиях, чтобы детерминированные стратегии могли выдать другой код.
+type CodeGenerator interface {
+	Generate(ctx context.Context, originalURL string, attempt int) (string, error)
+}
+
+// Grower реализуют стратегии, которые умеют удлинять коды при заполнении пространства
+type Grower interface {
+	Grow()
+}
+
+// Counter выдаёт монотонно возрастающие номера для последовательных стратегий
+type Counter interface {
+	Next(ctx context.Context) (uint64, error)
+}
+
+type Options struct {
+	Strategy string
+	Alphabet string
+	Length   int
+	Salt     string
+}
+
+func New(opts Options, counter Counter) (CodeGenerator, error) {
+	alphabet := opts.Alphabet
+	if alphabet == "" {
+		alphabet = Base62Alphabet
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if opts.Length <= 0 || opts.Length > MaxLength {
+		return nil, fmt.Errorf("short code length must be between 1 and %d, got %d", MaxLength, opts.Length)
+	}
+
+	switch opts.Strategy {
+	case StrategyRandom, "":
+		return NewRandom(alphabet, opts.Length), nil
+	case StrategySequence:
+		return NewSequence(alphabet, counter), nil
+	case StrategyHash:
+		return NewHash(alphabet, opts.Length), nil
+	case StrategySqids:
+		return NewSqids(alphabet, opts.Length, opts.Salt, counter)
+	default:
+		return nil, fmt.Errorf("unknown short code strategy %q", opts.Strategy)
+	}
+}
+
+func validateAlphabet(alphabet string) error {
+	runes := []rune(alphabet)
+	if len(runes) < 2 {
+		return fmt.Errorf("short code alphabet must contain at least 2 characters")
+	}
+	seen := make(map[rune]struct{}, len(runes))
+	for _, r := range runes {
+		if _, ok := seen[r]; ok {
+			return fmt.Errorf("short code alphabet contains duplicate character %q", r)
+		}
+		seen[r] = struct{}{}
+	}
+	return nil
+}
+
+// encode переводит число в систему счисления по основанию длины алфавита
+func encode(n uint64, alphabet []rune) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return string(alphabet[0])
+	}
+	var b []rune
+	for n > 0 {
+		b = append(b, alphabet[n%base])
+		n /= base
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,69 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// размер блока номеров, который FileCounter резервирует одной записью на диск
+const counterBlockSize = 100
+
+// FileCounter хранит счётчик рядом с файловым хранилищем.
+// На диск пишется верхняя граница зарезервированного блока, поэтому после рестарта
+// нумерация продолжается с неё (с возможным пропуском номеров), но никогда не повторяется.
+type FileCounter struct {
+	mu      sync.Mutex
+	path    string
+	current uint64
+	ceiling uint64
+}
+
+func NewFileCounter(path string) (*FileCounter, error) {
+	c := &FileCounter{path: path}
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ceiling, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	c.current, c.ceiling = ceiling, ceiling
+	return c, nil
+}
+
+func (c *FileCounter) Next(_ context.Context) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current >= c.ceiling && c.path != "" {
+		ceiling := c.current + counterBlockSize
+		err := writeFileAtomic(c.path, []byte(strconv.FormatUint(ceiling, 10)))
+		if err != nil {
+			return 0, err
+		}
+		c.ceiling = ceiling
+	}
+	c.current++
+	return c.current, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	err := os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
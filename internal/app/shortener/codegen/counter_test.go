@@ -0,0 +1,72 @@
+package codegen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCounter_restart(t *testing.T) {
+	tests := []struct {
+		name    string
+		calls   int
+		ceiling string
+		next    uint64
+	}{
+		{name: "fresh file", calls: 0, ceiling: "", next: 1},
+		{name: "first block", calls: 1, ceiling: "100", next: 101},
+		{name: "block exhausted", calls: counterBlockSize, ceiling: "100", next: 101},
+		{name: "second block", calls: counterBlockSize + 1, ceiling: "200", next: 201},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "counter")
+
+			c, err := NewFileCounter(path)
+			require.NoError(t, err)
+			for i := 1; i <= test.calls; i++ {
+				n, err := c.Next(ctx)
+				require.NoError(t, err)
+				require.Equal(t, uint64(i), n)
+			}
+
+			data, err := os.ReadFile(path)
+			if test.ceiling == "" {
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.ceiling, string(data))
+			}
+
+			// после рестарта номера продолжаются с границы блока и не повторяются
+			restarted, err := NewFileCounter(path)
+			require.NoError(t, err)
+			n, err := restarted.Next(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, test.next, n)
+		})
+	}
+}
+
+func TestFileCounter_inMemory(t *testing.T) {
+	c, err := NewFileCounter("")
+	require.NoError(t, err)
+	for i := uint64(1); i <= 3; i++ {
+		n, err := c.Next(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, i, n)
+	}
+}
+
+func TestFileCounter_corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	require.NoError(t, os.WriteFile(path, []byte("not a number"), 0600))
+
+	_, err := NewFileCounter(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package codegen
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+	"strconv"
+)
+
+// Hash выдаёт детерминированный код по хешу исходной ссылки.
+// При коллизии номер попытки подмешивается в хеш.
+type Hash struct {
+	alphabet []rune
+	length   int
+}
+
+func NewHash(alphabet string, length int) *Hash {
+	return &Hash{alphabet: []rune(alphabet), length: length}
+}
+
+func (g *Hash) Generate(_ context.Context, originalURL string, attempt int) (string, error) {
+	input := originalURL
+	if attempt > 0 {
+		input += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+
+	n := new(big.Int).SetBytes(sum[:])
+	base := big.NewInt(int64(len(g.alphabet)))
+	mod := new(big.Int)
+	b := make([]rune, 0, g.length)
+	for len(b) < g.length {
+		if n.Sign() == 0 {
+			sum = sha256.Sum256(sum[:])
+			n.SetBytes(sum[:])
+		}
+		n.DivMod(n, base, mod)
+		b = append(b, g.alphabet[mod.Int64()])
+	}
+	return string(b), nil
+}
@@ -0,0 +1,54 @@
+package codegen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash_Generate(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+	}{
+		{name: "base62", alphabet: Base62Alphabet, length: 7},
+		{name: "single char", alphabet: Base62Alphabet, length: 1},
+		{name: "longer than sha256", alphabet: Base62Alphabet, length: MaxLength},
+		{name: "binary alphabet", alphabet: "01", length: 16},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			code, err := NewHash(test.alphabet, test.length).Generate(ctx, "https://ya.ru", 0)
+			require.NoError(t, err)
+			assert.Len(t, code, test.length)
+			for _, r := range code {
+				assert.Contains(t, test.alphabet, string(r))
+			}
+
+			// тот же адрес в другом экземпляре даёт тот же код
+			again, err := NewHash(test.alphabet, test.length).Generate(ctx, "https://ya.ru", 0)
+			require.NoError(t, err)
+			assert.Equal(t, code, again)
+		})
+	}
+}
+
+func TestHash_differentInput(t *testing.T) {
+	ctx := context.Background()
+	g := NewHash(Base62Alphabet, 7)
+
+	code, err := g.Generate(ctx, "https://ya.ru", 0)
+	require.NoError(t, err)
+	other, err := g.Generate(ctx, "https://example.com", 0)
+	require.NoError(t, err)
+	retry, err := g.Generate(ctx, "https://ya.ru", 1)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, code, other)
+	assert.NotEqual(t, code, retry)
+}
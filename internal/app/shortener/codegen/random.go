@@ -0,0 +1,54 @@
+package codegen
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const maxRandomLength = 32
+
+// Random выдаёт случайные коды заданной длины из алфавита
+type Random struct {
+	alphabet []rune
+	length   atomic.Int32
+}
+
+func NewRandom(alphabet string, length int) *Random {
+	g := &Random{alphabet: []rune(alphabet)}
+	g.length.Store(int32(length))
+	return g
+}
+
+func (g *Random) Length() int {
+	return int(g.length.Load())
+}
+
+func (g *Random) Generate(_ context.Context, _ string, _ int) (string, error) {
+	b := make([]rune, g.Length())
+	max := big.NewInt(int64(len(g.alphabet)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = g.alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// Grow увеличивает длину кода на единицу
+func (g *Random) Grow() {
+	length := g.length.Load()
+	if length >= maxRandomLength {
+		return
+	}
+	if g.length.CompareAndSwap(length, length+1) {
+		logger.Log.Info("short url length increased", zap.Int32("length", length+1))
+	}
+}
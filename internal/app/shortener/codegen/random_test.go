@@ -0,0 +1,61 @@
+package codegen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandom_Generate(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+	}{
+		{name: "base62", alphabet: Base62Alphabet, length: 5},
+		{name: "binary alphabet", alphabet: "ab", length: 8},
+		{name: "unicode alphabet", alphabet: "абвгд", length: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewRandom(test.alphabet, test.length)
+			for i := 0; i < 100; i++ {
+				code, err := g.Generate(context.Background(), "https://ya.ru", i)
+				require.NoError(t, err)
+				require.Len(t, []rune(code), test.length)
+				for _, r := range code {
+					require.Contains(t, test.alphabet, string(r))
+				}
+			}
+		})
+	}
+}
+
+func TestRandom_Grow(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		grows  int
+		want   int
+	}{
+		{name: "grows by one", length: 5, grows: 1, want: 6},
+		{name: "grows up to limit", length: maxRandomLength - 1, grows: 1, want: maxRandomLength},
+		{name: "stops at limit", length: maxRandomLength - 1, grows: 5, want: maxRandomLength},
+		{name: "already at limit", length: maxRandomLength, grows: 1, want: maxRandomLength},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewRandom(Base62Alphabet, test.length)
+			for i := 0; i < test.grows; i++ {
+				g.Grow()
+			}
+			assert.Equal(t, test.want, g.Length())
+
+			code, err := g.Generate(context.Background(), "https://ya.ru", 0)
+			require.NoError(t, err)
+			assert.Len(t, code, test.want)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+const (
+	maxAttempts = 10
+	// после стольких коллизий подряд считаем, что пространство кодов текущей длины забито
+	growAfter = 3
+)
+
+// ErrCollision возвращается из функции резервирования, если такой код уже занят
+var ErrCollision = errors.New("short url already exists")
+
+// ErrExhausted возвращается, если за отведённое число попыток не удалось найти свободный код
+var ErrExhausted = errors.New("unable to generate unique short url")
+
+// коды, совпадающие с сегментами маршрутов сервиса: их нельзя ни выбрать алиасом, ни сгенерировать
+var reservedCodes = map[string]struct{}{
+	"api":      {},
+	"ping":     {},
+	"admin":    {},
+	"aliases":  {},
+	"debug":    {},
+	"health":   {},
+	"internal": {},
+	"metrics":  {},
+	"static":   {},
+	"user":     {},
+}
+
+// IsReserved сообщает, что код без учёта регистра совпадает с зарезервированным словом
+func IsReserved(code string) bool {
+	_, ok := reservedCodes[strings.ToLower(code)]
+	return ok
+}
+
+// Reserver получает коды от стратегии и гарантирует их уникальность через функцию резервирования.
+// При частых коллизиях просит стратегию удлинить коды, если она это умеет.
+type Reserver struct {
+	generator CodeGenerator
+}
+
+func NewReserver(generator CodeGenerator) *Reserver {
+	return &Reserver{generator: generator}
+}
+
+// Reserve генерирует коды, пока reserve не примет один из них
+func (r *Reserver) Reserve(ctx context.Context, originalURL string, reserve func(code string) error) (string, error) {
+	var code string
+	err := r.retry(func(attempt int) error {
+		var err error
+		code, err = r.generator.Generate(ctx, originalURL, attempt)
+		if err != nil {
+			return err
+		}
+		if IsReserved(code) {
+			return ErrCollision
+		}
+		return reserve(code)
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ReserveBatch генерирует различные коды для всех ссылок и перегенерирует их все,
+// если reserve сообщил о коллизии. Непустые fixed[i] используются как есть.
+func (r *Reserver) ReserveBatch(
+	ctx context.Context,
+	originalURLs []string,
+	fixed []string,
+	reserve func(codes []string) error,
+) ([]string, error) {
+	var codes []string
+	err := r.retry(func(attempt int) error {
+		codes = make([]string, 0, len(originalURLs))
+		seen := make(map[string]struct{}, len(originalURLs))
+		for _, code := range fixed {
+			if code != "" {
+				seen[code] = struct{}{}
+			}
+		}
+		for i, originalURL := range originalURLs {
+			if i < len(fixed) && fixed[i] != "" {
+				codes = append(codes, fixed[i])
+				continue
+			}
+			code, err := r.unique(ctx, originalURL, attempt, seen)
+			if err != nil {
+				return err
+			}
+			codes = append(codes, code)
+		}
+		return reserve(codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// unique выдаёт незарезервированный код, которого ещё нет среди уже выданных в этой пачке
+func (r *Reserver) unique(ctx context.Context, originalURL string, attempt int, seen map[string]struct{}) (string, error) {
+	for i := 0; i < maxAttempts; i++ {
+		code, err := r.generator.Generate(ctx, originalURL, attempt+i*maxAttempts)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := seen[code]; !ok && !IsReserved(code) {
+			seen[code] = struct{}{}
+			return code, nil
+		}
+	}
+	return "", ErrExhausted
+}
+
+func (r *Reserver) retry(try func(attempt int) error) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err := try(attempt)
+		if !errors.Is(err, ErrCollision) {
+			return err
+		}
+		if grower, ok := r.generator.(Grower); ok && (attempt+1)%growAfter == 0 {
+			grower.Grow()
+		}
+	}
+	return ErrExhausted
+}
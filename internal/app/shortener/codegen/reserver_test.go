@@ -0,0 +1,159 @@
+package codegen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserver_Reserve(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retries on collision", func(t *testing.T) {
+		r := NewReserver(NewRandom(Base62Alphabet, 5))
+		calls := 0
+		code, err := r.Reserve(ctx, "https://ya.ru", func(code string) error {
+			calls++
+			if calls < 2 {
+				return ErrCollision
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Len(t, code, 5)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("grows length when saturated", func(t *testing.T) {
+		g := NewRandom(Base62Alphabet, 5)
+		code, err := NewReserver(g).Reserve(ctx, "https://ya.ru", func(code string) error {
+			if len(code) == 5 {
+				return ErrCollision
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Len(t, code, 6)
+		assert.Equal(t, 6, g.Length())
+	})
+
+	t.Run("hash strategy changes code on collision", func(t *testing.T) {
+		var codes []string
+		_, err := NewReserver(NewHash(Base62Alphabet, 7)).Reserve(ctx, "https://ya.ru", func(code string) error {
+			codes = append(codes, code)
+			if len(codes) < 2 {
+				return ErrCollision
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.NotEqual(t, codes[0], codes[1])
+	})
+
+	t.Run("gives up after budget", func(t *testing.T) {
+		_, err := NewReserver(NewRandom(Base62Alphabet, 5)).Reserve(ctx, "https://ya.ru", func(code string) error {
+			return ErrCollision
+		})
+		assert.ErrorIs(t, err, ErrExhausted)
+	})
+}
+
+func TestReserver_ReserveBatch(t *testing.T) {
+	ctx := context.Background()
+	urls := []string{"https://ya.ru", "https://ya.ru", "https://example.com"}
+
+	tests := []struct {
+		name      string
+		generator CodeGenerator
+	}{
+		{name: "random", generator: NewRandom(Base62Alphabet, 1)},
+		{name: "hash with duplicate urls", generator: NewHash(Base62Alphabet, 5)},
+		{name: "sequence", generator: NewSequence(Base62Alphabet, &FileCounter{})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codes, err := NewReserver(test.generator).ReserveBatch(ctx, urls, nil, func(codes []string) error {
+				return nil
+			})
+			require.NoError(t, err)
+
+			unique := make(map[string]struct{})
+			for _, code := range codes {
+				unique[code] = struct{}{}
+			}
+			assert.Len(t, unique, len(urls))
+		})
+	}
+}
+
+func TestReserver_ReserveBatchFixed(t *testing.T) {
+	codes, err := NewReserver(NewRandom("ab", 1)).ReserveBatch(
+		context.Background(),
+		[]string{"https://ya.ru", "https://example.com"},
+		[]string{"a", ""},
+		func(codes []string) error {
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, codes)
+}
+
+// scriptedGenerator выдаёт коды по порядку, не глядя на номер попытки
+type scriptedGenerator struct {
+	codes []string
+}
+
+func (g *scriptedGenerator) Generate(context.Context, string, int) (string, error) {
+	code := g.codes[0]
+	g.codes = g.codes[1:]
+	return code, nil
+}
+
+// Сгенерированный код, как и алиас, не может совпасть с сегментом маршрута
+func TestReserver_reservedWords(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("single", func(t *testing.T) {
+		var reserved []string
+		code, err := NewReserver(&scriptedGenerator{codes: []string{"ping", "PING", "abcde"}}).Reserve(
+			ctx, "https://ya.ru", func(code string) error {
+				reserved = append(reserved, code)
+				return nil
+			},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "abcde", code)
+		assert.Equal(t, []string{"abcde"}, reserved)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		codes, err := NewReserver(&scriptedGenerator{codes: []string{"api", "abcde", "metrics", "fghij"}}).ReserveBatch(
+			ctx, []string{"https://ya.ru", "https://example.com"}, nil, func(codes []string) error {
+				return nil
+			},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"abcde", "fghij"}, codes)
+	})
+}
+
+func TestIsReserved(t *testing.T) {
+	tests := []struct {
+		code     string
+		reserved bool
+	}{
+		{code: "ping", reserved: true},
+		{code: "Metrics", reserved: true},
+		{code: "api", reserved: true},
+		{code: "pings", reserved: false},
+		{code: "abcde", reserved: false},
+	}
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+			assert.Equal(t, test.reserved, IsReserved(test.code))
+		})
+	}
+}
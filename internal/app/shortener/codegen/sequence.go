@@ -0,0 +1,27 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+)
+
+// Sequence кодирует номер из монотонного счётчика в base62 (или другой заданный алфавит)
+type Sequence struct {
+	alphabet []rune
+	counter  Counter
+}
+
+func NewSequence(alphabet string, counter Counter) *Sequence {
+	return &Sequence{alphabet: []rune(alphabet), counter: counter}
+}
+
+func (g *Sequence) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	if g.counter == nil {
+		return "", errors.New("sequence strategy requires a counter")
+	}
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encode(n, g.alphabet), nil
+}
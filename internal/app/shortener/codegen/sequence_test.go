@@ -0,0 +1,65 @@
+package codegen
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedCounter всегда выдаёт один и тот же номер
+type fixedCounter uint64
+
+func (c fixedCounter) Next(context.Context) (uint64, error) {
+	return uint64(c), nil
+}
+
+// decode - обратное к encode преобразование для проверки порядка кодов
+func decode(code string, alphabet string) uint64 {
+	var n uint64
+	for _, r := range code {
+		n = n*uint64(len([]rune(alphabet))) + uint64(strings.IndexRune(alphabet, r))
+	}
+	return n
+}
+
+func TestSequence_Generate(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		code string
+	}{
+		{n: 1, code: "1"},
+		{n: 10, code: "a"},
+		{n: 61, code: "Z"},
+		{n: 62, code: "10"},
+		{n: 3843, code: "ZZ"},
+		{n: 3844, code: "100"},
+	}
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+			code, err := NewSequence(Base62Alphabet, fixedCounter(test.n)).Generate(context.Background(), "https://ya.ru", 0)
+			require.NoError(t, err)
+			assert.Equal(t, test.code, code)
+		})
+	}
+}
+
+func TestSequence_monotonic(t *testing.T) {
+	g := NewSequence(Base62Alphabet, &FileCounter{})
+
+	var prev uint64
+	for i := 0; i < 5000; i++ {
+		code, err := g.Generate(context.Background(), "https://ya.ru", 0)
+		require.NoError(t, err)
+		n := decode(code, Base62Alphabet)
+		require.Greater(t, n, prev, code)
+		prev = n
+	}
+}
+
+func TestSequence_withoutCounter(t *testing.T) {
+	_, err := NewSequence(Base62Alphabet, nil).Generate(context.Background(), "https://ya.ru", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package codegen
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/sqids/sqids-go"
+)
+
+// Sqids кодирует номер из счётчика в обфусцированный идентификатор sqids.
+// Алфавит перемешивается детерминированно по соли, так что по коду нельзя угадать соседние.
+type Sqids struct {
+	sqids   *sqids.Sqids
+	counter Counter
+}
+
+func NewSqids(alphabet string, minLength int, salt string, counter Counter) (*Sqids, error) {
+	if minLength < 0 || minLength > MaxLength {
+		return nil, fmt.Errorf("sqids min length must be between 0 and %d, got %d", MaxLength, minLength)
+	}
+	s, err := sqids.New(sqids.Options{
+		Alphabet:  shuffle(alphabet, salt),
+		MinLength: uint8(minLength),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Sqids{sqids: s, counter: counter}, nil
+}
+
+func (g *Sqids) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	if g.counter == nil {
+		return "", errors.New("sqids strategy requires a counter")
+	}
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return g.sqids.Encode([]uint64{n})
+}
+
+// shuffle переставляет символы алфавита детерминированно по соли (Фишер-Йетс на sha256)
+func shuffle(alphabet string, salt string) string {
+	runes := []rune(alphabet)
+	if salt == "" {
+		return alphabet
+	}
+	seed := sha256.Sum256([]byte(salt))
+	for i := len(runes) - 1; i > 0; i-- {
+		seed = sha256.Sum256(seed[:])
+		j := int(binary.BigEndian.Uint64(seed[:8]) % uint64(i+1))
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
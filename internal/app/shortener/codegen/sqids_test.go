@@ -0,0 +1,81 @@
+package codegen
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/sqids/sqids-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqids_Generate(t *testing.T) {
+	tests := []struct {
+		name      string
+		alphabet  string
+		minLength int
+		salt      string
+		n         uint64
+	}{
+		{name: "default", alphabet: Base62Alphabet, minLength: 6, n: 1},
+		{name: "salted", alphabet: Base62Alphabet, minLength: 6, salt: "secret", n: 1},
+		{name: "large number", alphabet: Base62Alphabet, minLength: 6, salt: "secret", n: 1 << 40},
+		{name: "short alphabet", alphabet: "abcdef", minLength: 4, salt: "secret", n: 42},
+		{name: "max length", alphabet: Base62Alphabet, minLength: MaxLength, salt: "secret", n: 7},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := NewSqids(test.alphabet, test.minLength, test.salt, fixedCounter(test.n))
+			require.NoError(t, err)
+			code, err := g.Generate(context.Background(), "https://ya.ru", 0)
+			require.NoError(t, err)
+
+			assert.GreaterOrEqual(t, len(code), test.minLength)
+			for _, r := range code {
+				assert.Contains(t, test.alphabet, string(r))
+			}
+
+			// код раскодируется обратно тем же перемешанным алфавитом
+			s, err := sqids.New(sqids.Options{Alphabet: shuffle(test.alphabet, test.salt), MinLength: uint8(test.minLength)})
+			require.NoError(t, err)
+			assert.Equal(t, []uint64{test.n}, s.Decode(code))
+		})
+	}
+}
+
+func TestSqids_invalidMinLength(t *testing.T) {
+	for _, minLength := range []int{-1, MaxLength + 1, 256} {
+		_, err := NewSqids(Base62Alphabet, minLength, "", &FileCounter{})
+		assert.Error(t, err, minLength)
+	}
+}
+
+func TestSqids_salt(t *testing.T) {
+	generate := func(salt string) string {
+		g, err := NewSqids(Base62Alphabet, 6, salt, fixedCounter(1))
+		require.NoError(t, err)
+		code, err := g.Generate(context.Background(), "https://ya.ru", 0)
+		require.NoError(t, err)
+		return code
+	}
+
+	assert.Equal(t, generate("secret"), generate("secret"))
+	assert.NotEqual(t, generate("secret"), generate("other"))
+	assert.NotEqual(t, generate(""), generate("secret"))
+}
+
+func TestShuffle(t *testing.T) {
+	sorted := func(s string) string {
+		runes := []rune(s)
+		sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+		return string(runes)
+	}
+
+	assert.Equal(t, Base62Alphabet, shuffle(Base62Alphabet, ""))
+	shuffled := shuffle(Base62Alphabet, "secret")
+	assert.NotEqual(t, Base62Alphabet, shuffled)
+	assert.Equal(t, shuffled, shuffle(Base62Alphabet, "secret"))
+	// перестановка, а не замена символов
+	assert.Equal(t, sorted(Base62Alphabet), sorted(shuffled))
+}
@@ -0,0 +1,107 @@
+package shortener
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// urlCounters поддерживает число живых ссылок и их владельцев без обхода всего хранилища.
+// Истёкшие ссылки перестают учитываться при первом же запросе статистики, не дожидаясь PurgeExpired.
+type urlCounters struct {
+	mu    sync.Mutex
+	urls  int64
+	users map[string]int64
+	// expiring - учтённые ссылки со сроком жизни, deadlines - их сроки по возрастанию
+	expiring  map[string]time.Time
+	deadlines deadlineHeap
+}
+
+func newURLCounters() *urlCounters {
+	return &urlCounters{users: make(map[string]int64), expiring: make(map[string]time.Time)}
+}
+
+func (c *urlCounters) add(su models.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if su.ExpiresAt != nil {
+		c.expiring[su.ShortURL] = *su.ExpiresAt
+		heap.Push(&c.deadlines, deadline{code: su.ShortURL, userID: su.UserID, at: *su.ExpiresAt})
+	}
+	c.inc(su.UserID)
+}
+
+// remove снимает ссылку с учёта, если она не была уже снята по истечении срока
+func (c *urlCounters) remove(su models.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if su.ExpiresAt != nil {
+		if _, ok := c.expiring[su.ShortURL]; !ok {
+			return
+		}
+		delete(c.expiring, su.ShortURL)
+	}
+	c.dec(su.UserID)
+}
+
+func (c *urlCounters) stats(now time.Time) *models.ServiceStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.deadlines) > 0 && !c.deadlines[0].at.After(now) {
+		d := heap.Pop(&c.deadlines).(deadline)
+		// в куче остаются и сроки уже удалённых ссылок
+		if at, ok := c.expiring[d.code]; !ok || !at.Equal(d.at) {
+			continue
+		}
+		delete(c.expiring, d.code)
+		c.dec(d.userID)
+	}
+	return &models.ServiceStats{URLs: c.urls, Users: int64(len(c.users))}
+}
+
+func (c *urlCounters) inc(userID string) {
+	c.urls++
+	if userID != "" {
+		c.users[userID]++
+	}
+}
+
+func (c *urlCounters) dec(userID string) {
+	c.urls--
+	if userID == "" {
+		return
+	}
+	c.users[userID]--
+	if c.users[userID] <= 0 {
+		delete(c.users, userID)
+	}
+}
+
+type deadline struct {
+	code   string
+	userID string
+	at     time.Time
+}
+
+// deadlineHeap - минимальная куча сроков для container/heap
+type deadlineHeap []deadline
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h deadlineHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *deadlineHeap) Push(x any) {
+	*h = append(*h, x.(deadline))
+}
+
+func (h *deadlineHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return d
+}
@@ -0,0 +1,62 @@
+package shortener
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// statsMapper - общее у файлового хранилища и хранилища в памяти
+type statsMapper interface {
+	Add(ctx context.Context, url models.URL) (string, error)
+	Delete(ctx context.Context, userID string, shortURLs []string) error
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+	Stats(ctx context.Context) (*models.ServiceStats, error)
+}
+
+// Статистика, как и запрос в postgres, не учитывает истёкшие, но ещё не вычищенные ссылки
+func TestStats_expiredLinks(t *testing.T) {
+	mappers := map[string]func(t *testing.T) statsMapper{
+		"memory": func(*testing.T) statsMapper { return newTestMemoryMapper() },
+		"file": func(t *testing.T) statsMapper {
+			return newTestFileMapper(filepath.Join(t.TempDir(), "db.json"))
+		},
+	}
+	for name, newMapper := range mappers {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newMapper(t)
+
+			expiresAt := time.Now().Add(20 * time.Millisecond)
+			expiring, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "first", ExpiresAt: &expiresAt})
+			require.NoError(t, err)
+			_, err = m.Add(ctx, models.URL{OriginalURL: "https://go.dev", UserID: "second", ExpiresAt: &expiresAt})
+			require.NoError(t, err)
+			_, err = m.Add(ctx, models.URL{OriginalURL: "https://example.com", UserID: "second"})
+			require.NoError(t, err)
+
+			stats, err := m.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, models.ServiceStats{URLs: 3, Users: 2}, *stats)
+
+			time.Sleep(time.Until(expiresAt) + time.Millisecond)
+			stats, err = m.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, models.ServiceStats{URLs: 1, Users: 1}, *stats)
+
+			// удаление и чистка уже не учтённых ссылок не уменьшают счётчики второй раз
+			require.NoError(t, m.Delete(ctx, "first", []string{expiring}))
+			_, err = m.PurgeExpired(ctx, time.Now())
+			require.NoError(t, err)
+			stats, err = m.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, models.ServiceStats{URLs: 1, Users: 1}, *stats)
+		})
+	}
+}
@@ -3,33 +3,48 @@ package shortener
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
 	dbErrs "github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/errs"
 	handlerErrs "github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
-	"github.com/AsakoKabe/go-yandex-shortener/internal/app/utils"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
 )
 
 type DBUrlMapper struct {
-	maxLenShortURL int
-	urlService     service.URLService
+	reserver   *codegen.Reserver
+	urlService service.URLService
 }
 
-func NewDBUrlMapper(maxLenShortURL int, urlService service.URLService) *DBUrlMapper {
-	return &DBUrlMapper{maxLenShortURL: maxLenShortURL, urlService: urlService}
+func NewDBUrlMapper(generator codegen.CodeGenerator, urlService service.URLService) *DBUrlMapper {
+	return &DBUrlMapper{reserver: codegen.NewReserver(generator), urlService: urlService}
 }
 
-func (m *DBUrlMapper) Add(ctx context.Context, originalURL string) (string, error) {
-	shortURL := utils.RandStringRunes(m.maxLenShortURL)
-	url := models.URL{
-		ShortURL:    shortURL,
-		OriginalURL: originalURL,
+// Add сохраняет ссылку. Если в url.ShortURL передан алиас, занимается ровно он.
+func (m *DBUrlMapper) Add(ctx context.Context, url models.URL) (string, error) {
+	var existedShortURL string
+	save := func(code string) error {
+		url.ShortURL = code
+		var err error
+		existedShortURL, err = m.urlService.SaveURL(ctx, url)
+		if errors.Is(err, dbErrs.ErrShortURLAlreadyExist) {
+			return codegen.ErrCollision
+		}
+		return err
+	}
+
+	var shortURL string
+	var err error
+	if url.ShortURL != "" {
+		shortURL, err = reserveAlias(url.ShortURL, save)
+	} else {
+		shortURL, err = m.reserver.Reserve(ctx, url.OriginalURL, save)
 	}
-	existedShortURL, err := m.urlService.SaveURL(ctx, url)
 	if errors.Is(err, dbErrs.ErrOriginalURLAlreadyExist) {
 		return existedShortURL, handlerErrs.ErrConflictOriginalURL
 	}
@@ -40,35 +55,106 @@ func (m *DBUrlMapper) Add(ctx context.Context, originalURL string) (string, erro
 	return shortURL, nil
 }
 
-func (m *DBUrlMapper) AddBatch(ctx context.Context, originalURLs []string) (*[]string, error) {
-	var batchURL []models.URL
-	var shortURLs []string
-
-	for _, originalURL := range originalURLs {
-		shortURL := utils.RandStringRunes(m.maxLenShortURL)
-		batchURL = append(batchURL, models.URL{
-			ShortURL:    shortURL,
-			OriginalURL: originalURL,
-		})
-		shortURLs = append(shortURLs, shortURL)
+// AddBatch сохраняет пачку одним запросом. Адреса, которые уже есть в БД,
+// получают существующие коды со статусом BatchExisting.
+func (m *DBUrlMapper) AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error) {
+	results, pending, err := planBatch(urls, func(code string) (bool, error) {
+		return m.taken(ctx, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return results, nil
 	}
 
-	err := m.urlService.SaveBatchURL(ctx, batchURL)
+	var saved map[string]models.BatchResult
+	originalURLs, aliases := pendingArgs(urls, pending)
+	_, err = m.reserver.ReserveBatch(ctx, originalURLs, aliases, func(codes []string) error {
+		batchURL := make([]models.URL, 0, len(codes))
+		for j, i := range pending {
+			url := urls[i]
+			url.ShortURL = codes[j]
+			batchURL = append(batchURL, url)
+		}
 
+		var err error
+		saved, err = m.urlService.SaveBatchURL(ctx, batchURL)
+		if errors.Is(err, dbErrs.ErrShortURLAlreadyExist) {
+			// алиас могли занять после planBatch: его нельзя перегенерировать, как случайный код
+			for _, alias := range aliases {
+				if alias == "" {
+					continue
+				}
+				taken, err := m.taken(ctx, alias)
+				if err != nil {
+					return err
+				}
+				if taken {
+					return handlerErrs.ErrAliasTaken
+				}
+			}
+			return codegen.ErrCollision
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &shortURLs, nil
+	for _, i := range pending {
+		result, ok := saved[urls[i].OriginalURL]
+		if !ok {
+			return nil, fmt.Errorf("no short url saved for %q", urls[i].OriginalURL)
+		}
+		results[i] = result
+	}
+	resolveDuplicates(urls, pending, results)
+	return results, nil
 }
 
-func (m *DBUrlMapper) Get(ctx context.Context, shortURL string) (string, bool) {
+// taken сообщает, занят ли код ссылкой, в том числе удалённой или истёкшей
+func (m *DBUrlMapper) taken(ctx context.Context, code string) (bool, error) {
+	_, err := m.Get(ctx, code)
+	if errors.Is(err, handlerErrs.ErrNotFound) {
+		return false, nil
+	}
+	if errors.Is(err, handlerErrs.ErrGone) {
+		return true, nil
+	}
+	return err == nil, err
+}
+
+// Get отличает отсутствующую ссылку (ErrNotFound) от недоступной БД (ErrUnavailable)
+func (m *DBUrlMapper) Get(ctx context.Context, shortURL string) (models.URL, error) {
 	su, err := m.urlService.GetURL(ctx, shortURL)
-	if err != nil {
-		logger.Log.Error("error to create short url", zap.String("err", err.Error()))
+	if errors.Is(err, dbErrs.ErrURLNotFound) {
+		return models.URL{}, handlerErrs.ErrNotFound
 	}
-	if su != nil {
-		return su.OriginalURL, true
+	if err != nil {
+		logger.FromContext(ctx).Error("error to get url", zap.String("err", err.Error()))
+		return models.URL{}, fmt.Errorf("%w: %w", handlerErrs.ErrUnavailable, err)
 	}
-	return "", false
+	return resolvable(*su, time.Now())
+}
+
+func (m *DBUrlMapper) GetByUserID(ctx context.Context, userID string) ([]models.URL, error) {
+	return m.urlService.GetURLsByUserID(ctx, userID)
+}
+
+func (m *DBUrlMapper) Delete(ctx context.Context, userID string, shortURLs []string) error {
+	return m.urlService.DeleteURLs(ctx, userID, shortURLs)
+}
+
+func (m *DBUrlMapper) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	return m.urlService.PurgeExpired(ctx, now)
+}
+
+func (m *DBUrlMapper) Stats(ctx context.Context) (*models.ServiceStats, error) {
+	return m.urlService.Stats(ctx)
+}
+
+// Close ничего не делает: пул соединений закрывает приложение
+func (m *DBUrlMapper) Close() error {
+	return nil
 }
@@ -0,0 +1,76 @@
+package shortener
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service"
+	dbErrs "github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// racingURLService хранит ссылки в памяти. Алиас из race занимается «другим клиентом»
+// в момент сохранения пачки, уже после проверки в planBatch.
+type racingURLService struct {
+	service.URLService
+	race string
+
+	mu    sync.Mutex
+	urls  map[string]models.URL
+	saves int
+}
+
+func (s *racingURLService) SaveBatchURL(_ context.Context, batchURL []models.URL) (map[string]models.BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saves++
+	results := make(map[string]models.BatchResult)
+	for _, url := range batchURL {
+		if url.ShortURL == s.race {
+			s.urls[s.race] = models.URL{ShortURL: s.race, OriginalURL: "https://other.example.com"}
+		}
+		if _, ok := s.urls[url.ShortURL]; ok {
+			return nil, dbErrs.ErrShortURLAlreadyExist
+		}
+		results[url.OriginalURL] = models.BatchResult{ShortURL: url.ShortURL, Status: models.BatchCreated}
+	}
+	for _, url := range batchURL {
+		s.urls[url.ShortURL] = url
+	}
+	return results, nil
+}
+
+func (s *racingURLService) GetURL(_ context.Context, shortURL string) (*models.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[shortURL]
+	if !ok {
+		return nil, dbErrs.ErrURLNotFound
+	}
+	return &url, nil
+}
+
+func TestDBUrlMapper_AddBatchAliasRace(t *testing.T) {
+	urlService := &racingURLService{race: "my-link", urls: make(map[string]models.URL)}
+	m := NewDBUrlMapper(codegen.NewRandom(codegen.Base62Alphabet, 6), urlService)
+
+	_, err := m.AddBatch(context.Background(), []models.URL{
+		{OriginalURL: "https://ya.ru"},
+		{OriginalURL: "https://example.com", ShortURL: "my-link"},
+	})
+	assert.ErrorIs(t, err, errs.ErrAliasTaken)
+	// занятый алиас не перебирается до codegen.ErrExhausted
+	assert.Equal(t, 1, urlService.saves)
+
+	results, err := m.AddBatch(context.Background(), []models.URL{{OriginalURL: "https://ya.ru"}})
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchCreated, results[0].Status)
+}
@@ -0,0 +1,39 @@
+package shortener
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// ExpiresAt вычисляет момент истечения ссылки из срока жизни ttl или абсолютного времени expiresAt
+func ExpiresAt(ttl string, expiresAt *time.Time, now time.Time) (*time.Time, error) {
+	if ttl != "" && expiresAt != nil {
+		return nil, errors.New("only one of ttl and expires_at can be set")
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, errors.New("ttl must be positive")
+		}
+		at := now.Add(d)
+		return &at, nil
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	return expiresAt, nil
+}
+
+// resolvable возвращает ссылку вместе с errs.ErrGone, если она удалена или истекла
+func resolvable(url models.URL, now time.Time) (models.URL, error) {
+	if url.DeletedFlag || url.Expired(now) {
+		return url, errs.ErrGone
+	}
+	return url, nil
+}
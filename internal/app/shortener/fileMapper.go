@@ -3,133 +3,443 @@ package shortener
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/filelog"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
-	"github.com/AsakoKabe/go-yandex-shortener/internal/app/utils"
 	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
-	"go.uber.org/zap"
-	"io"
-	"os"
-	"strings"
-	"sync"
 )
 
+// fileLogFormat - имя формата в заголовке файла хранилища
+const fileLogFormat = "shortener-urls"
+
+// FileOptions задают, как часто файл хранилища синхронизируется с диском
+type FileOptions struct {
+	Sync         filelog.SyncPolicy
+	SyncInterval time.Duration
+}
+
+// FileURLMapper держит ссылки в памяти и дописывает изменения в лог на диске,
+// из которого восстанавливается при старте. Удаление пишется надгробной записью,
+// а PurgeExpired переписывает лог без удалённых и истёкших ссылок, оставляя от них только коды.
 type FileURLMapper struct {
-	mapping         sync.Map
-	maxLenShortURL  int
-	fileStoragePath string
-	fileMutex       sync.Mutex
+	mapping  sync.Map
+	reserver *codegen.Reserver
+	log      *filelog.Log
+	counters *urlCounters
+	// indexMutex делает проверку конфликтов и резервирование кодов атомарными
+	indexMutex sync.Mutex
+	originals  originalIndex
+	// pending - ссылки, занявшие код и адрес, но ещё не записанные в лог
+	pending map[string]models.URL
+	// запись в лог и её применение к памяти идут под RLock, снимок для компакции -
+	// под Lock, чтобы в него попало всё, что уже записано
+	commitMutex sync.RWMutex
+	// retired - владельцы по кодам вычищенных PurgeExpired ссылок: эти коды не выдают повторно
+	retired sync.Map
 }
 
-func NewFileURLMapper(maxLenShortURL int, fileStoragePath string) *FileURLMapper {
-	mapper := &FileURLMapper{
-		maxLenShortURL:  maxLenShortURL,
-		fileStoragePath: fileStoragePath,
-	}
-	err := mapper.loadFromFile()
+// fileRecord - строка лога: ссылка, надгробие удаления или вычищенный код
+type fileRecord struct {
+	models.URL
+	Retired bool `json:"retired,omitempty"`
+}
+
+// NewFileURLMapper открывает хранилище с синхронизацией раз в секунду и паникует, если файл не читается
+func NewFileURLMapper(generator codegen.CodeGenerator, fileStoragePath string) *FileURLMapper {
+	mapper, err := OpenFileURLMapper(generator, fileStoragePath, FileOptions{
+		Sync:         filelog.SyncInterval,
+		SyncInterval: time.Second,
+	})
 	if err != nil {
 		panic(err)
 	}
 	return mapper
 }
 
-func (m *FileURLMapper) Add(_ context.Context, url string) (string, error) {
-	shortURL := utils.RandStringRunes(m.maxLenShortURL)
-	su := models.URL{
-		ShortURL:    shortURL,
-		OriginalURL: url,
+func OpenFileURLMapper(generator codegen.CodeGenerator, fileStoragePath string, options FileOptions) (*FileURLMapper, error) {
+	m := &FileURLMapper{
+		reserver:  codegen.NewReserver(generator),
+		counters:  newURLCounters(),
+		originals: make(originalIndex),
+		pending:   make(map[string]models.URL),
 	}
-	m.mapping.Store(shortURL, su)
-	err := m.saveToFile(su)
+
+	var err error
+	m.log, err = filelog.Open(fileStoragePath, filelog.Options{
+		Format:       fileLogFormat,
+		Sync:         options.Sync,
+		SyncInterval: options.SyncInterval,
+	}, m.replay)
 	if err != nil {
-		return "", err
+		logger.Log.Error(
+			"error to open file storage",
+			zap.String("file path", fileStoragePath),
+			zap.String("err", err.Error()),
+		)
+		return nil, err
 	}
-	return shortURL, nil
+
+	m.mapping.Range(func(_, value any) bool {
+		if su := value.(models.URL); !su.DeletedFlag {
+			m.counters.add(su)
+		}
+		return true
+	})
+	return m, nil
 }
 
-func (m *FileURLMapper) AddBatch(_ context.Context, originalURLs []string) (*[]string, error) {
-	var shortURLs []string
-	for _, originalURL := range originalURLs {
-		shortURL := utils.RandStringRunes(m.maxLenShortURL)
-		su := models.URL{
-			ShortURL:    shortURL,
-			OriginalURL: originalURL,
+// Add сохраняет ссылку. Если в url.ShortURL передан алиас, занимается ровно он.
+// Если адрес уже сокращён, возвращает его код вместе с ErrConflictOriginalURL.
+func (m *FileURLMapper) Add(ctx context.Context, url models.URL) (string, error) {
+	var existedShortURL string
+	store := func(code string) error {
+		url.ShortURL = code
+		m.indexMutex.Lock()
+		if existed, ok := m.liveCode(url.OriginalURL, time.Now()); ok {
+			m.indexMutex.Unlock()
+			existedShortURL = existed
+			return errs.ErrConflictOriginalURL
 		}
-		m.mapping.Store(shortURL, su)
-		shortURLs = append(shortURLs, shortURL)
-		err := m.saveToFile(su)
-		if err != nil {
-			return nil, err
+		if m.taken(code) {
+			m.indexMutex.Unlock()
+			return codegen.ErrCollision
 		}
+		m.reserve(url)
+		m.indexMutex.Unlock()
+
+		return m.commit(url)
 	}
 
-	return &shortURLs, nil
+	var shortURL string
+	var err error
+	if url.ShortURL != "" {
+		shortURL, err = reserveAlias(url.ShortURL, store)
+	} else {
+		shortURL, err = m.reserver.Reserve(ctx, url.OriginalURL, store)
+	}
+	if errors.Is(err, errs.ErrConflictOriginalURL) {
+		return existedShortURL, err
+	}
+	return shortURL, err
 }
 
-func (m *FileURLMapper) Get(_ context.Context, shortURL string) (string, bool) {
-	su, ok := m.mapping.Load(shortURL)
+// AddBatch сохраняет пачку целиком или не сохраняет ничего: все записи дописываются
+// в лог одним вызовом. Для уже сокращённых адресов возвращаются их существующие
+// коды со статусом BatchExisting.
+func (m *FileURLMapper) AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error) {
+	results, pending, err := planBatch(urls, func(code string) (bool, error) {
+		m.indexMutex.Lock()
+		defer m.indexMutex.Unlock()
+		return m.taken(code), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	originalURLs, aliases := pendingArgs(urls, pending)
+	_, err = m.reserver.ReserveBatch(ctx, originalURLs, aliases, func(codes []string) error {
+		m.indexMutex.Lock()
+		now := time.Now()
+		fresh := make([]models.URL, 0, len(codes))
+		for j, code := range codes {
+			i := pending[j]
+			if existed, ok := m.liveCode(urls[i].OriginalURL, now); ok {
+				results[i] = models.BatchResult{ShortURL: existed, Status: models.BatchExisting}
+				continue
+			}
+			if m.taken(code) {
+				m.indexMutex.Unlock()
+				if aliases[j] != "" {
+					return errs.ErrAliasTaken
+				}
+				return codegen.ErrCollision
+			}
+			results[i] = models.BatchResult{ShortURL: code, Status: models.BatchCreated}
+			url := urls[i]
+			url.ShortURL = code
+			fresh = append(fresh, url)
+		}
+		for _, su := range fresh {
+			m.reserve(su)
+		}
+		m.indexMutex.Unlock()
+
+		return m.commit(fresh...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resolveDuplicates(urls, pending, results)
+	return results, nil
+}
+
+// reserve занимает код и исходный адрес до окончания записи в лог. Вызывается под indexMutex.
+func (m *FileURLMapper) reserve(su models.URL) {
+	m.pending[su.ShortURL] = su
+	m.originals.set(su)
+}
+
+// commit дописывает зарезервированные ссылки в лог одной записью и переносит их в память.
+// Если запись не удалась, резерв снимается со всей пачки.
+func (m *FileURLMapper) commit(urls ...models.URL) error {
+	m.commitMutex.RLock()
+	defer m.commitMutex.RUnlock()
 
+	var data []byte
+	for _, su := range urls {
+		data = appendRecord(data, su)
+	}
+	err := m.log.Append(data)
+	if err != nil {
+		logger.Log.Error("error to save urls to file", zap.String("err", err.Error()))
+	}
+
+	m.indexMutex.Lock()
+	defer m.indexMutex.Unlock()
+	for _, su := range urls {
+		delete(m.pending, su.ShortURL)
+		if err != nil {
+			m.originals.forget(su)
+			continue
+		}
+		m.mapping.Store(su.ShortURL, su)
+		m.counters.add(su)
+	}
+	return err
+}
+
+func (m *FileURLMapper) Get(_ context.Context, shortURL string) (models.URL, error) {
+	su, ok := m.mapping.Load(shortURL)
 	if ok {
-		return su.(models.URL).OriginalURL, true
+		return resolvable(su.(models.URL), time.Now())
+	}
+	if userID, ok := m.retired.Load(shortURL); ok {
+		return models.URL{ShortURL: shortURL, UserID: userID.(string)}, errs.ErrGone
 	}
-	return "", false
+	return models.URL{}, errs.ErrNotFound
 }
 
-func (m *FileURLMapper) loadFromFile() error {
-	data, err := os.ReadFile(m.fileStoragePath)
-	if os.IsNotExist(err) {
+func (m *FileURLMapper) GetByUserID(_ context.Context, userID string) ([]models.URL, error) {
+	now := time.Now()
+	var urls []models.URL
+	m.mapping.Range(func(_, value any) bool {
+		su := value.(models.URL)
+		if su.UserID == userID && !su.DeletedFlag && !su.Expired(now) {
+			urls = append(urls, su)
+		}
+		return true
+	})
+	return urls, nil
+}
+
+// Delete помечает ссылки пользователя удалёнными и дописывает в лог надгробные записи одним вызовом
+func (m *FileURLMapper) Delete(_ context.Context, userID string, shortURLs []string) error {
+	m.commitMutex.RLock()
+	defer m.commitMutex.RUnlock()
+
+	var data []byte
+	var deleted []string
+	for _, shortURL := range shortURLs {
+		value, ok := m.mapping.Load(shortURL)
+		if !ok {
+			continue
+		}
+		su := value.(models.URL)
+		if su.UserID != userID || su.DeletedFlag {
+			continue
+		}
+		data = appendRecord(data, models.URL{ShortURL: shortURL, UserID: userID, DeletedFlag: true})
+		deleted = append(deleted, shortURL)
+	}
+	if len(deleted) == 0 {
 		return nil
 	}
+
+	err := m.log.Append(data)
 	if err != nil {
-		logger.Log.Error(
-			"error to read file",
-			zap.String("file path", m.fileStoragePath),
-			zap.String("err", err.Error()),
-		)
+		logger.Log.Error("error to save tombstones to file", zap.String("err", err.Error()))
 		return err
 	}
 
-	dec := json.NewDecoder(strings.NewReader(string(data)))
-	for {
-		var su models.URL
-
-		err = dec.Decode(&su)
-		if err == io.EOF {
-			break
+	m.indexMutex.Lock()
+	defer m.indexMutex.Unlock()
+	for _, shortURL := range deleted {
+		value, ok := m.mapping.Load(shortURL)
+		if !ok {
+			continue
 		}
-		if err != nil {
-			logger.Log.Error("error to parse json", zap.String("err", err.Error()))
-			return err
+		su := value.(models.URL)
+		if su.DeletedFlag {
+			continue
 		}
-		m.mapping.Store(su.ShortURL, su)
+		su.DeletedFlag = true
+		m.mapping.Store(shortURL, su)
+		m.counters.remove(su)
+		m.originals.forget(su)
 	}
-
 	return nil
 }
 
-func (m *FileURLMapper) saveToFile(su models.URL) error {
-	m.fileMutex.Lock()
-	defer m.fileMutex.Unlock()
+func (m *FileURLMapper) Stats(_ context.Context) (*models.ServiceStats, error) {
+	return m.counters.stats(time.Now()), nil
+}
 
-	content, _ := json.Marshal(su)
+// PurgeExpired переписывает лог без истёкших и удалённых ссылок и убирает их из памяти.
+// Их коды остаются в логе и в памяти занятыми, чтобы опубликованная короткая ссылка
+// не начала вести на другой адрес. Запись новых ссылок на время компакции не останавливается.
+func (m *FileURLMapper) PurgeExpired(_ context.Context, now time.Time) (int64, error) {
+	if !m.hasGarbage(now) {
+		return 0, nil
+	}
+
+	var dropped []models.URL
+	err := m.log.Compact(func() ([]byte, error) {
+		m.commitMutex.Lock()
+		defer m.commitMutex.Unlock()
 
-	f, err := os.OpenFile(m.fileStoragePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		var data []byte
+		m.mapping.Range(func(_, value any) bool {
+			su := value.(models.URL)
+			if su.DeletedFlag || su.Expired(now) {
+				dropped = append(dropped, su)
+				return true
+			}
+			data = appendRecord(data, su)
+			return true
+		})
+		m.retired.Range(func(code, userID any) bool {
+			data = appendRetired(data, models.URL{ShortURL: code.(string), UserID: userID.(string)})
+			return true
+		})
+		for _, su := range dropped {
+			data = appendRetired(data, su)
+		}
+		return data, nil
+	})
 	if err != nil {
-		logger.Log.Error(
-			"error to open file",
-			zap.String("file path", m.fileStoragePath),
-			zap.String("err", err.Error()),
-		)
+		logger.Log.Error("error to compact file", zap.String("err", err.Error()))
+		return 0, err
 	}
-	defer f.Close()
 
-	_, err = f.Write(content)
+	m.indexMutex.Lock()
+	defer m.indexMutex.Unlock()
+	for _, su := range dropped {
+		m.retired.Store(su.ShortURL, su.UserID)
+		value, ok := m.mapping.LoadAndDelete(su.ShortURL)
+		if !ok {
+			continue
+		}
+		su = value.(models.URL)
+		m.originals.forget(su)
+		if !su.DeletedFlag {
+			m.counters.remove(su)
+		}
+	}
+	return int64(len(dropped)), nil
+}
+
+// Close дописывает принятые записи и закрывает файл
+func (m *FileURLMapper) Close() error {
+	return m.log.Close()
+}
+
+func (m *FileURLMapper) hasGarbage(now time.Time) bool {
+	found := false
+	m.mapping.Range(func(_, value any) bool {
+		su := value.(models.URL)
+		found = su.DeletedFlag || su.Expired(now)
+		return !found
+	})
+	return found
+}
+
+// replay применяет запись лога при загрузке. Повтор записи ничего не меняет.
+func (m *FileURLMapper) replay(record []byte) error {
+	var rec fileRecord
+	err := json.Unmarshal(record, &rec)
 	if err != nil {
-		logger.Log.Error(
-			"error to save data to file",
-			zap.String("file path", m.fileStoragePath),
-			zap.String("err", err.Error()),
-		)
 		return err
 	}
+	su := rec.URL
+	if rec.Retired {
+		m.retired.Store(su.ShortURL, su.UserID)
+		if value, ok := m.mapping.LoadAndDelete(su.ShortURL); ok {
+			m.originals.forget(value.(models.URL))
+		}
+		return nil
+	}
+	if isTombstone(su) {
+		m.applyTombstone(su)
+		return nil
+	}
+	m.mapping.Store(su.ShortURL, su)
+	if !su.DeletedFlag {
+		m.originals.set(su)
+	}
 	return nil
 }
+
+// isTombstone отличает запись об удалении от полной записи удалённой ссылки
+func isTombstone(su models.URL) bool {
+	return su.DeletedFlag && su.OriginalURL == ""
+}
+
+func (m *FileURLMapper) applyTombstone(tombstone models.URL) {
+	value, ok := m.mapping.Load(tombstone.ShortURL)
+	if !ok {
+		return
+	}
+	su := value.(models.URL)
+	if su.UserID != tombstone.UserID {
+		return
+	}
+	su.DeletedFlag = true
+	m.mapping.Store(su.ShortURL, su)
+	m.originals.forget(su)
+}
+
+// liveCode ищет код живой ссылки на адрес, в том числе ещё не записанной в лог.
+// Вызывается под indexMutex.
+func (m *FileURLMapper) liveCode(originalURL string, now time.Time) (string, bool) {
+	return m.originals.live(originalURL, now, m.lookup)
+}
+
+// taken сообщает, занят ли код записанной, записываемой или вычищенной ссылкой. Вызывается под indexMutex.
+func (m *FileURLMapper) taken(code string) bool {
+	if _, ok := m.lookup(code); ok {
+		return true
+	}
+	_, ok := m.retired.Load(code)
+	return ok
+}
+
+func (m *FileURLMapper) lookup(code string) (models.URL, bool) {
+	if su, ok := m.pending[code]; ok {
+		return su, true
+	}
+	su, ok := m.mapping.Load(code)
+	if !ok {
+		return models.URL{}, false
+	}
+	return su.(models.URL), true
+}
+
+func appendRecord(data []byte, su models.URL) []byte {
+	record, _ := json.Marshal(su)
+	data = append(data, record...)
+	return append(data, '\n')
+}
+
+// appendRetired записывает от ссылки только код и владельца
+func appendRetired(data []byte, su models.URL) []byte {
+	record, _ := json.Marshal(fileRecord{URL: models.URL{ShortURL: su.ShortURL, UserID: su.UserID}, Retired: true})
+	data = append(data, record...)
+	return append(data, '\n')
+}
@@ -0,0 +1,201 @@
+package shortener
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/filelog"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+func newTestFileMapper(path string) *FileURLMapper {
+	return NewFileURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 6), path)
+}
+
+func TestFileURLMapper_conflictSurvivesReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	m := newTestFileMapper(path)
+
+	shortURL, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user"})
+	require.NoError(t, err)
+	deleted, err := m.Add(ctx, models.URL{OriginalURL: "https://example.com", UserID: "user"})
+	require.NoError(t, err)
+	require.NoError(t, m.Delete(ctx, "user", []string{deleted}))
+
+	existed, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+	assert.ErrorIs(t, err, errs.ErrConflictOriginalURL)
+	assert.Equal(t, shortURL, existed)
+
+	reloaded := newTestFileMapper(path)
+	existed, err = reloaded.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+	assert.ErrorIs(t, err, errs.ErrConflictOriginalURL)
+	assert.Equal(t, shortURL, existed)
+
+	// удалённый адрес можно сократить заново и после перезапуска
+	again, err := reloaded.Add(ctx, models.URL{OriginalURL: "https://example.com"})
+	require.NoError(t, err)
+	assert.NotEqual(t, deleted, again)
+}
+
+func TestFileURLMapper_AddBatch(t *testing.T) {
+	ctx := context.Background()
+	m := newTestFileMapper(filepath.Join(t.TempDir(), "db.json"))
+	existed, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+	require.NoError(t, err)
+
+	results, err := m.AddBatch(ctx, []models.URL{
+		{OriginalURL: "https://example.com"},
+		{OriginalURL: "https://ya.ru"},
+		{OriginalURL: "https://example.com"},
+		{OriginalURL: "example.com"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, models.BatchCreated, results[0].Status)
+	assert.Equal(t, models.BatchResult{ShortURL: existed, Status: models.BatchExisting}, results[1])
+	assert.Equal(t, models.BatchResult{ShortURL: results[0].ShortURL, Status: models.BatchExisting}, results[2])
+	assert.Equal(t, models.BatchInvalid, results[3].Status)
+	assert.ErrorIs(t, results[3].Err, errs.ErrInvalidURL)
+}
+
+func TestFileURLMapper_AddBatchRollback(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	m := newTestFileMapper(path)
+	_, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user"})
+	require.NoError(t, err)
+
+	// лог закрыт, запись не удаётся - пачка не должна остаться в памяти даже частично
+	require.NoError(t, m.Close())
+	_, err = m.AddBatch(ctx, []models.URL{
+		{OriginalURL: "https://example.com", UserID: "user"},
+		{OriginalURL: "https://go.dev", ShortURL: "go-dev", UserID: "user"},
+	})
+	require.Error(t, err)
+
+	urls, err := m.GetByUserID(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+	_, err = m.Get(ctx, "go-dev")
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+	stats, err := m.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.URLs)
+	assert.Empty(t, m.pending)
+	assert.Len(t, m.originals, 1)
+
+	reloaded := newTestFileMapper(path)
+	results, err := reloaded.AddBatch(ctx, []models.URL{{OriginalURL: "https://example.com", UserID: "user"}})
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchCreated, results[0].Status)
+}
+
+func TestFileURLMapper_PurgeExpired(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	m := newTestFileMapper(path)
+
+	expiresAt := time.Now().Add(time.Hour)
+	expiring, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	deleted, err := m.Add(ctx, models.URL{OriginalURL: "https://example.com", UserID: "user"})
+	require.NoError(t, err)
+	live, err := m.Add(ctx, models.URL{OriginalURL: "https://go.dev", UserID: "user"})
+	require.NoError(t, err)
+	require.NoError(t, m.Delete(ctx, "user", []string{deleted}))
+
+	purged, err := m.PurgeExpired(ctx, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	_, err = m.Get(ctx, expiring)
+	assert.ErrorIs(t, err, errs.ErrGone)
+	// вычищенный код не выдаётся повторно
+	_, err = m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", ShortURL: expiring})
+	assert.ErrorIs(t, err, errs.ErrAliasTaken)
+	stats, err := m.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.URLs)
+
+	// после компакции в логе заголовок, живая ссылка и коды вычищенных
+	require.NoError(t, m.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[1], live)
+	for _, line := range lines[2:] {
+		assert.Contains(t, line, `"retired":true`)
+		assert.NotContains(t, line, "original_url")
+	}
+
+	reloaded := newTestFileMapper(path)
+	su, err := reloaded.Get(ctx, live)
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.dev", su.OriginalURL)
+	for _, code := range []string{expiring, deleted} {
+		_, err = reloaded.Get(ctx, code)
+		assert.ErrorIs(t, err, errs.ErrGone)
+	}
+	purged, err = reloaded.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func TestFileURLMapper_legacyFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	legacy := `{"ID":0,"short_url":"abc","original_url":"https://ya.ru","user_id":"user"}` +
+		`{"ID":0,"short_url":"def","original_url":"https://example.com","user_id":"user"}` +
+		`{"ID":0,"short_url":"def","user_id":"user","is_deleted":true}`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0600))
+
+	m := newTestFileMapper(path)
+	su, err := m.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", su.OriginalURL)
+	_, err = m.Get(ctx, "def")
+	assert.ErrorIs(t, err, errs.ErrGone)
+	require.NoError(t, m.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `{"format":"shortener-urls","version":1}`+"\n"))
+}
+
+// BenchmarkFileURLMapper_Add сравнивает политики синхронизации на параллельном сокращении ссылок
+func BenchmarkFileURLMapper_Add(b *testing.B) {
+	for _, policy := range []filelog.SyncPolicy{filelog.SyncNever, filelog.SyncInterval, filelog.SyncAlways} {
+		b.Run(string(policy), func(b *testing.B) {
+			m, err := OpenFileURLMapper(
+				codegen.NewRandom(codegen.Base62Alphabet, 8),
+				filepath.Join(b.TempDir(), "db.json"),
+				FileOptions{Sync: policy, SyncInterval: time.Second},
+			)
+			require.NoError(b, err)
+			defer m.Close()
+
+			var n atomic.Int64
+			b.SetParallelism(8)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					url := models.URL{OriginalURL: "https://example.com/" + strconv.FormatInt(n.Add(1), 10)}
+					if _, err := m.Add(context.Background(), url); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
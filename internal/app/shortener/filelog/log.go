@@ -0,0 +1,449 @@
+package filelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Version - версия формата: заголовок и по одной JSON записи на строку.
+// Файлы без заголовка (версия 0) - склеенные без разделителей JSON объекты.
+const Version = 1
+
+// maxGroup ограничивает число записей, которые писатель объединяет в один вызов Write
+const maxGroup = 1024
+
+var (
+	ErrClosed     = errors.New("log is closed")
+	ErrCompacting = errors.New("log compaction is already running")
+)
+
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+type Options struct {
+	// Format записывается в заголовок и сверяется при открытии, чтобы не прочитать чужой файл
+	Format       string
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+}
+
+type request struct {
+	data []byte
+	// op выполняется писателем между группами, например замена файла после компакции
+	op   func() error
+	done chan error
+}
+
+// Log - файл, в который записи только дописываются. Одновременные Append собираются
+// фоновым писателем в одну запись на диск (group commit), а синхронизация с диском
+// идёт по политике Options.Sync. Compact переписывает файл, не останавливая запись.
+type Log struct {
+	path    string
+	options Options
+	header  []byte
+
+	// file, size и dirty принадлежат горутине писателя
+	file  *os.File
+	size  int64
+	dirty bool
+
+	requests chan request
+	stopped  chan struct{}
+	// closeMutex не даёт отправить запрос в уже закрытый канал
+	closeMutex sync.RWMutex
+	closed     bool
+
+	// capture собирает записи, дописанные во время компакции
+	captureMutex sync.Mutex
+	capture      *bytes.Buffer
+}
+
+// Open открывает лог, создавая его при необходимости, и передаёт replay все записи по порядку.
+// Оборванная последняя запись, не успевшая дописаться до перевода строки, отбрасывается.
+// Файл без заголовка переписывается в текущем формате.
+func Open(path string, options Options, replay func(record []byte) error) (*Log, error) {
+	if options.Sync == SyncInterval && options.SyncInterval <= 0 {
+		return nil, fmt.Errorf("sync interval must be positive, got %s", options.SyncInterval)
+	}
+	head, _ := json.Marshal(header{Format: options.Format, Version: Version})
+	l := &Log{
+		path:     path,
+		options:  options,
+		header:   append(head, '\n'),
+		requests: make(chan request, maxGroup),
+		stopped:  make(chan struct{}),
+	}
+
+	err := l.load(replay)
+	if err != nil {
+		return nil, err
+	}
+
+	l.file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	l.size = info.Size()
+
+	go l.run()
+	return l, nil
+}
+
+// Append дописывает data - одну или несколько записей, каждая с переводом строки в конце.
+// Данные одного вызова попадают в файл целиком или не попадают вовсе.
+// Возврат без ошибки означает, что данные записаны и синхронизированы по политике Sync.
+func (l *Log) Append(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if data[len(data)-1] != '\n' {
+		return errors.New("record must end with a newline")
+	}
+	return l.send(request{data: data})
+}
+
+// Compact переписывает лог содержимым snapshot, не останавливая Append. Перехват новых
+// записей включается до вызова snapshot, поэтому snapshot должен вернуть как минимум всё,
+// что было дописано до него, а записи, попавшие и в snapshot, и в перехват, повторятся
+// после снимка: их применение при чтении должно быть идемпотентным.
+func (l *Log) Compact(snapshot func() ([]byte, error)) error {
+	l.captureMutex.Lock()
+	if l.capture != nil {
+		l.captureMutex.Unlock()
+		return ErrCompacting
+	}
+	l.capture = new(bytes.Buffer)
+	l.captureMutex.Unlock()
+	defer l.stopCapture()
+
+	data, err := snapshot()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return errors.New("record must end with a newline")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".compact-*")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	_, err = tmp.Write(l.header)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	// хвост дописывается и файл подменяется писателем, так что между ними ничего не теряется
+	return l.send(request{op: func() error {
+		tail := l.stopCapture()
+		_, err := tmp.Write(tail)
+		if err == nil {
+			err = tmp.Sync()
+		}
+		if err == nil {
+			err = tmp.Close()
+		}
+		if err != nil {
+			return err
+		}
+		// новый дескриптор открывается до переименования: после него писать в старый файл уже нельзя
+		file, err := os.OpenFile(tmp.Name(), os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(tmp.Name(), l.path)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		committed = true
+		syncDir(l.path)
+
+		l.file.Close()
+		l.file = file
+		l.size = int64(len(l.header) + len(data) + len(tail))
+		l.dirty = false
+		return nil
+	}})
+}
+
+// Close дожидается записи уже принятых данных, синхронизирует файл с диском и закрывает его
+func (l *Log) Close() error {
+	l.closeMutex.Lock()
+	if l.closed {
+		l.closeMutex.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.requests)
+	l.closeMutex.Unlock()
+
+	<-l.stopped
+	err := l.file.Sync()
+	return errors.Join(err, l.file.Close())
+}
+
+func (l *Log) send(req request) error {
+	req.done = make(chan error, 1)
+	l.closeMutex.RLock()
+	if l.closed {
+		l.closeMutex.RUnlock()
+		return ErrClosed
+	}
+	l.requests <- req
+	l.closeMutex.RUnlock()
+	return <-req.done
+}
+
+// run - цикл писателя. Пока он пишет группу, новые запросы копятся в канале
+// и уходят на диск следующей группой.
+func (l *Log) run() {
+	defer close(l.stopped)
+
+	var tick <-chan time.Time
+	if l.options.Sync == SyncInterval {
+		ticker := time.NewTicker(l.options.SyncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var group []request
+	for {
+		var req request
+		ok := true
+		if len(group) > 0 {
+			select {
+			case req, ok = <-l.requests:
+			default:
+				l.commit(group)
+				group = group[:0]
+				continue
+			}
+		} else {
+			select {
+			case req, ok = <-l.requests:
+			case <-tick:
+				l.syncDirty()
+				continue
+			}
+		}
+
+		if !ok || req.op != nil {
+			if len(group) > 0 {
+				l.commit(group)
+				group = group[:0]
+			}
+			if !ok {
+				return
+			}
+			req.done <- req.op()
+			continue
+		}
+		group = append(group, req)
+		if len(group) == maxGroup {
+			l.commit(group)
+			group = group[:0]
+		}
+	}
+}
+
+func (l *Log) commit(group []request) {
+	var data []byte
+	if len(group) == 1 {
+		data = group[0].data
+	} else {
+		for _, req := range group {
+			data = append(data, req.data...)
+		}
+	}
+
+	err := l.write(data)
+	for _, req := range group {
+		req.done <- err
+	}
+}
+
+// write дописывает группу. При ошибке файл обрезается до прежнего размера,
+// чтобы в логе не осталось части группы.
+func (l *Log) write(data []byte) error {
+	_, err := l.file.Write(data)
+	if err == nil && l.options.Sync == SyncAlways {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		return errors.Join(err, l.file.Truncate(l.size))
+	}
+
+	l.size += int64(len(data))
+	l.dirty = l.options.Sync == SyncInterval
+	l.captureMutex.Lock()
+	if l.capture != nil {
+		l.capture.Write(data)
+	}
+	l.captureMutex.Unlock()
+	return nil
+}
+
+func (l *Log) syncDirty() {
+	if !l.dirty {
+		return
+	}
+	// ошибка повторится на следующем тике или в Close
+	if l.file.Sync() == nil {
+		l.dirty = false
+	}
+}
+
+// stopCapture выключает перехват и возвращает перехваченные записи
+func (l *Log) stopCapture() []byte {
+	l.captureMutex.Lock()
+	defer l.captureMutex.Unlock()
+
+	if l.capture == nil {
+		return nil
+	}
+	tail := l.capture.Bytes()
+	l.capture = nil
+	return tail
+}
+
+func (l *Log) load(replay func(record []byte) error) error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return l.rewrite(nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	first, err := r.ReadBytes('\n')
+	if err == io.EOF && len(first) == 0 {
+		return l.rewrite(nil)
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var h header
+	if json.Unmarshal(first, &h) != nil || h.Format == "" {
+		return l.upgrade(f, replay)
+	}
+	if h.Format != l.options.Format {
+		return fmt.Errorf("%s: unexpected log format %q", l.path, h.Format)
+	}
+	if h.Version > Version {
+		return fmt.Errorf("%s: unsupported log version %d", l.path, h.Version)
+	}
+
+	size := int64(len(first))
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// запись оборвалась на середине, она не была подтверждена
+				return os.Truncate(l.path, size)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size += int64(len(line))
+		err = replay(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			return fmt.Errorf("%s: %w", l.path, err)
+		}
+	}
+}
+
+// upgrade читает файл версии 0 и переписывает его записи в текущем формате
+func (l *Log) upgrade(f *os.File, replay func(record []byte) error) error {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	dec := json.NewDecoder(f)
+	for {
+		var record json.RawMessage
+		err = dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", l.path, err)
+		}
+		err = replay(record)
+		if err != nil {
+			return fmt.Errorf("%s: %w", l.path, err)
+		}
+		data = append(data, record...)
+		data = append(data, '\n')
+	}
+	return l.rewrite(data)
+}
+
+// rewrite атомарно заменяет файл заголовком и data
+func (l *Log) rewrite(data []byte) error {
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(l.header)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	err = errors.Join(err, f.Close())
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(l.path)
+	return nil
+}
+
+// syncDir сохраняет на диске переименование файла. Ошибка не критична:
+// данные уже синхронизированы, в худшем случае после сбоя останется старый файл.
+func syncDir(path string) {
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return
+	}
+	dir.Sync()
+	dir.Close()
+}
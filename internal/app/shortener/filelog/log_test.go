@@ -0,0 +1,219 @@
+package filelog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testOptions = Options{Format: "test", Sync: SyncInterval, SyncInterval: time.Millisecond}
+
+func openTestLog(t *testing.T, path string) (*Log, []string) {
+	var records []string
+	l, err := Open(path, testOptions, func(record []byte) error {
+		records = append(records, string(record))
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return l, records
+}
+
+func TestLog_reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	l, records := openTestLog(t, path)
+	assert.Empty(t, records)
+
+	require.NoError(t, l.Append([]byte("{\"a\":1}\n")))
+	require.NoError(t, l.Append([]byte("{\"b\":2}\n{\"c\":3}\n")))
+	assert.Error(t, l.Append([]byte("{\"d\":4}")))
+	require.NoError(t, l.Close())
+	assert.ErrorIs(t, l.Append([]byte("{\"e\":5}\n")), ErrClosed)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"format\":\"test\",\"version\":1}\n{\"a\":1}\n{\"b\":2}\n{\"c\":3}\n", string(data))
+
+	_, records = openTestLog(t, path)
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, records)
+}
+
+func TestLog_tornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	head := "{\"format\":\"test\",\"version\":1}\n{\"a\":1}\n"
+	require.NoError(t, os.WriteFile(path, []byte(head+"{\"b\":"), 0600))
+
+	l, records := openTestLog(t, path)
+	assert.Equal(t, []string{`{"a":1}`}, records)
+
+	require.NoError(t, l.Append([]byte("{\"c\":3}\n")))
+	require.NoError(t, l.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, head+"{\"c\":3}\n", string(data))
+}
+
+func TestLog_upgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(path, []byte(`{"a":1}{"b":2}`), 0600))
+
+	_, records := openTestLog(t, path)
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, records)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"format\":\"test\",\"version\":1}\n{\"a\":1}\n{\"b\":2}\n", string(data))
+}
+
+func TestLog_header(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+
+	require.NoError(t, os.WriteFile(path, []byte("{\"format\":\"other\",\"version\":1}\n"), 0600))
+	_, err := Open(path, testOptions, func([]byte) error { return nil })
+	assert.ErrorContains(t, err, "unexpected log format")
+
+	require.NoError(t, os.WriteFile(path, []byte("{\"format\":\"test\",\"version\":2}\n"), 0600))
+	_, err = Open(path, testOptions, func([]byte) error { return nil })
+	assert.ErrorContains(t, err, "unsupported log version")
+}
+
+func TestLog_groupCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	l, _ := openTestLog(t, path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, l.Append([]byte(fmt.Sprintf("{\"i\":%d}\n", i))))
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, l.Close())
+
+	_, records := openTestLog(t, path)
+	assert.Len(t, records, 100)
+}
+
+func TestLog_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	l, _ := openTestLog(t, path)
+	require.NoError(t, l.Append([]byte("{\"a\":1}\n{\"b\":2}\n")))
+
+	err := l.Compact(func() ([]byte, error) {
+		// запись во время компакции попадает после снимка
+		require.NoError(t, l.Append([]byte("{\"c\":3}\n")))
+		return []byte("{\"b\":2}\n"), nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, l.Append([]byte("{\"d\":4}\n")))
+	require.NoError(t, l.Close())
+
+	_, records := openTestLog(t, path)
+	assert.Equal(t, []string{`{"b":2}`, `{"c":3}`, `{"d":4}`}, records)
+
+	leftovers, err := filepath.Glob(path + ".compact-*")
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestLog_CompactFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	l, _ := openTestLog(t, path)
+	require.NoError(t, l.Append([]byte("{\"a\":1}\n")))
+
+	err := l.Compact(func() ([]byte, error) {
+		return nil, fmt.Errorf("snapshot failed")
+	})
+	require.Error(t, err)
+	require.NoError(t, l.Append([]byte("{\"b\":2}\n")))
+	require.NoError(t, l.Close())
+
+	_, records := openTestLog(t, path)
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, records)
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, s := range []string{"always", "interval", "never"} {
+		policy, err := ParseSyncPolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, SyncPolicy(s), policy)
+	}
+	_, err := ParseSyncPolicy("sometimes")
+	assert.Error(t, err)
+}
+
+// clients - число одновременных писателей на каждый процессор в бенчмарках:
+// выигрыш group commit виден, когда запросы приходят параллельно
+const clients = 8
+
+// legacyAppend повторяет прежнюю запись файлового хранилища: открыть, дописать, закрыть
+func legacyAppend(mu *sync.Mutex, path string, data []byte, fsync bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	if err == nil && fsync {
+		err = f.Sync()
+	}
+	return err
+}
+
+// BenchmarkAppend сравнивает прежнюю запись (legacy, legacy-fsync - она же с fsync
+// на каждую ссылку) с логом при разных политиках синхронизации
+func BenchmarkAppend(b *testing.B) {
+	record := []byte(`{"ID":0,"short_url":"abcdef","original_url":"https://example.com/` +
+		strings.Repeat("x", 40) + `","user_id":"0123456789abcdef0123456789abcdef"}` + "\n")
+
+	for _, fsync := range []bool{false, true} {
+		name := "legacy"
+		if fsync {
+			name = "legacy-fsync"
+		}
+		b.Run(name, func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "log")
+			var mu sync.Mutex
+			b.SetParallelism(clients)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := legacyAppend(&mu, path, record, fsync); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+
+	for _, policy := range []SyncPolicy{SyncNever, SyncInterval, SyncAlways} {
+		b.Run(string(policy), func(b *testing.B) {
+			options := Options{Format: "bench", Sync: policy, SyncInterval: time.Second}
+			l, err := Open(filepath.Join(b.TempDir(), "log"), options, func([]byte) error { return nil })
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer l.Close()
+
+			b.SetParallelism(clients)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := l.Append(record); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package filelog
+
+import "fmt"
+
+// SyncPolicy определяет, когда записанные данные синхронизируются с диском
+type SyncPolicy string
+
+const (
+	// SyncAlways синхронизирует каждую группу до подтверждения записи
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval синхронизирует раз в Options.SyncInterval: при сбое ОС теряется не больше интервала
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever оставляет синхронизацию операционной системе
+	SyncNever SyncPolicy = "never"
+)
+
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(s); policy {
+	case SyncAlways, SyncInterval, SyncNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown sync policy %q", s)
+	}
+}
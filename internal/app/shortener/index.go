@@ -0,0 +1,35 @@
+package shortener
+
+import (
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// originalIndex - обратный индекс original_url -> short_url для хранилищ без уникального
+// ограничения на исходный адрес. Доступ синхронизирует владелец индекса.
+type originalIndex map[string]string
+
+// live возвращает код неудалённой и неистёкшей ссылки на originalURL
+func (i originalIndex) live(originalURL string, now time.Time, get func(code string) (models.URL, bool)) (string, bool) {
+	code, ok := i[originalURL]
+	if !ok {
+		return "", false
+	}
+	su, ok := get(code)
+	if !ok || su.DeletedFlag || su.Expired(now) {
+		return "", false
+	}
+	return code, true
+}
+
+func (i originalIndex) set(su models.URL) {
+	i[su.OriginalURL] = su.ShortURL
+}
+
+// forget убирает адрес из индекса, если он всё ещё указывает на эту ссылку
+func (i originalIndex) forget(su models.URL) {
+	if i[su.OriginalURL] == su.ShortURL {
+		delete(i, su.OriginalURL)
+	}
+}
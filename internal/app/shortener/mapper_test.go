@@ -0,0 +1,76 @@
+package shortener
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/dbtest"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/migrations"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/db/service/postgres"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// urlMapper - общее у всех хранилищ ссылок
+type urlMapper interface {
+	Add(ctx context.Context, url models.URL) (string, error)
+	AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error)
+	Get(ctx context.Context, shortURL string) (models.URL, error)
+	Delete(ctx context.Context, userID string, shortURLs []string) error
+}
+
+// testMappers возвращает хранилища всех видов. Postgres проверяется только с TEST_DATABASE_DSN.
+func testMappers() map[string]func(t *testing.T) urlMapper {
+	return map[string]func(t *testing.T) urlMapper{
+		"memory": func(*testing.T) urlMapper { return newTestMemoryMapper() },
+		"file": func(t *testing.T) urlMapper {
+			return newTestFileMapper(filepath.Join(t.TempDir(), "db.json"))
+		},
+		"postgres": func(t *testing.T) urlMapper {
+			db := dbtest.Open(t)
+			migrator, err := migrations.NewMigrator(db)
+			require.NoError(t, err)
+			require.NoError(t, migrator.Up(context.Background()))
+
+			urlService, err := postgres.NewURLService(db)
+			require.NoError(t, err)
+			return NewDBUrlMapper(codegen.NewRandom(codegen.Base62Alphabet, 5), urlService)
+		},
+	}
+}
+
+// Удалённый адрес во всех хранилищах сокращается заново под новым кодом, а старый код остаётся удалённым
+func TestMappers_reshortenDeleted(t *testing.T) {
+	for name, newMapper := range testMappers() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newMapper(t)
+
+			first, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user"})
+			require.NoError(t, err)
+			require.NoError(t, m.Delete(ctx, "user", []string{first}))
+
+			second, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user"})
+			require.NoError(t, err)
+			assert.NotEqual(t, first, second)
+			_, err = m.Get(ctx, first)
+			assert.ErrorIs(t, err, errs.ErrGone)
+
+			require.NoError(t, m.Delete(ctx, "user", []string{second}))
+			results, err := m.AddBatch(ctx, []models.URL{{OriginalURL: "https://ya.ru", UserID: "user"}})
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, models.BatchCreated, results[0].Status)
+			assert.NotContains(t, []string{first, second}, results[0].ShortURL)
+
+			su, err := m.Get(ctx, results[0].ShortURL)
+			require.NoError(t, err)
+			assert.Equal(t, "https://ya.ru", su.OriginalURL)
+		})
+	}
+}
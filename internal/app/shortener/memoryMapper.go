@@ -0,0 +1,215 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+// MemoryURLMapper хранит ссылки только в памяти процесса: для тестов и окружений,
+// которым не нужно переживать перезапуск
+type MemoryURLMapper struct {
+	mu sync.RWMutex
+	// urls - ссылки по коротким кодам, originals - обратный индекс по исходному адресу
+	urls      map[string]models.URL
+	originals originalIndex
+	reserver  *codegen.Reserver
+	counters  *urlCounters
+	// retired - владельцы по кодам удалённых PurgeExpired ссылок: эти коды не выдают повторно
+	retired map[string]string
+}
+
+func NewMemoryURLMapper(generator codegen.CodeGenerator) *MemoryURLMapper {
+	return &MemoryURLMapper{
+		urls:      make(map[string]models.URL),
+		originals: make(originalIndex),
+		retired:   make(map[string]string),
+		reserver:  codegen.NewReserver(generator),
+		counters:  newURLCounters(),
+	}
+}
+
+// Add сохраняет ссылку. Если адрес уже сокращён, возвращает его код вместе с ErrConflictOriginalURL.
+func (m *MemoryURLMapper) Add(ctx context.Context, url models.URL) (string, error) {
+	var existedShortURL string
+	store := func(code string) error {
+		url.ShortURL = code
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if existed, ok := m.liveCode(url.OriginalURL, time.Now()); ok {
+			existedShortURL = existed
+			return errs.ErrConflictOriginalURL
+		}
+		if m.taken(code) {
+			return codegen.ErrCollision
+		}
+		m.put(url)
+		return nil
+	}
+
+	var shortURL string
+	var err error
+	if url.ShortURL != "" {
+		shortURL, err = reserveAlias(url.ShortURL, store)
+	} else {
+		shortURL, err = m.reserver.Reserve(ctx, url.OriginalURL, store)
+	}
+	if errors.Is(err, errs.ErrConflictOriginalURL) {
+		return existedShortURL, err
+	}
+	return shortURL, err
+}
+
+// AddBatch сохраняет пачку целиком под одной блокировкой. Для уже сокращённых
+// адресов возвращаются их существующие коды со статусом BatchExisting.
+func (m *MemoryURLMapper) AddBatch(ctx context.Context, urls []models.URL) ([]models.BatchResult, error) {
+	results, pending, err := planBatch(urls, func(code string) (bool, error) {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.taken(code), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	originalURLs, aliases := pendingArgs(urls, pending)
+	_, err = m.reserver.ReserveBatch(ctx, originalURLs, aliases, func(codes []string) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		now := time.Now()
+		fresh := make([]bool, len(codes))
+		for j, code := range codes {
+			i := pending[j]
+			if existed, ok := m.liveCode(urls[i].OriginalURL, now); ok {
+				results[i] = models.BatchResult{ShortURL: existed, Status: models.BatchExisting}
+				continue
+			}
+			if m.taken(code) {
+				if aliases[j] != "" {
+					return errs.ErrAliasTaken
+				}
+				return codegen.ErrCollision
+			}
+			results[i] = models.BatchResult{ShortURL: code, Status: models.BatchCreated}
+			fresh[j] = true
+		}
+		for j, i := range pending {
+			if fresh[j] {
+				url := urls[i]
+				url.ShortURL = codes[j]
+				m.put(url)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	resolveDuplicates(urls, pending, results)
+	return results, nil
+}
+
+func (m *MemoryURLMapper) Get(_ context.Context, shortURL string) (models.URL, error) {
+	m.mu.RLock()
+	su, ok := m.urls[shortURL]
+	userID, retired := m.retired[shortURL]
+	m.mu.RUnlock()
+	if retired {
+		return models.URL{ShortURL: shortURL, UserID: userID}, errs.ErrGone
+	}
+	if !ok {
+		return models.URL{}, errs.ErrNotFound
+	}
+	return resolvable(su, time.Now())
+}
+
+func (m *MemoryURLMapper) GetByUserID(_ context.Context, userID string) ([]models.URL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var urls []models.URL
+	for _, su := range m.urls {
+		if su.UserID == userID && !su.DeletedFlag && !su.Expired(now) {
+			urls = append(urls, su)
+		}
+	}
+	return urls, nil
+}
+
+// Delete помечает ссылки пользователя удалёнными, их адреса можно сократить заново
+func (m *MemoryURLMapper) Delete(_ context.Context, userID string, shortURLs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, shortURL := range shortURLs {
+		su, ok := m.urls[shortURL]
+		if !ok || su.UserID != userID || su.DeletedFlag {
+			continue
+		}
+		su.DeletedFlag = true
+		m.urls[shortURL] = su
+		m.originals.forget(su)
+		m.counters.remove(su)
+	}
+	return nil
+}
+
+func (m *MemoryURLMapper) Stats(_ context.Context) (*models.ServiceStats, error) {
+	return m.counters.stats(time.Now()), nil
+}
+
+func (m *MemoryURLMapper) Close() error {
+	return nil
+}
+
+// PurgeExpired удаляет истёкшие ссылки. Их коды остаются занятыми, чтобы опубликованная
+// короткая ссылка не начала вести на другой адрес.
+func (m *MemoryURLMapper) PurgeExpired(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for code, su := range m.urls {
+		if !su.Expired(now) {
+			continue
+		}
+		delete(m.urls, code)
+		m.retired[code] = su.UserID
+		m.originals.forget(su)
+		if !su.DeletedFlag {
+			m.counters.remove(su)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// taken сообщает, занят ли код ссылкой, в том числе удалённой, истёкшей или вычищенной
+func (m *MemoryURLMapper) taken(code string) bool {
+	if _, ok := m.urls[code]; ok {
+		return true
+	}
+	_, ok := m.retired[code]
+	return ok
+}
+
+func (m *MemoryURLMapper) liveCode(originalURL string, now time.Time) (string, bool) {
+	return m.originals.live(originalURL, now, func(code string) (models.URL, bool) {
+		su, ok := m.urls[code]
+		return su, ok
+	})
+}
+
+func (m *MemoryURLMapper) put(su models.URL) {
+	m.urls[su.ShortURL] = su
+	m.originals.set(su)
+	m.counters.add(su)
+}
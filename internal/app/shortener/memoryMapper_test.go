@@ -0,0 +1,112 @@
+package shortener
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/codegen"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/shortener/models"
+)
+
+func newTestMemoryMapper() *MemoryURLMapper {
+	return NewMemoryURLMapper(codegen.NewRandom(codegen.Base62Alphabet, 6))
+}
+
+func TestMemoryURLMapper_Add(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryMapper()
+
+	shortURL, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user"})
+	require.NoError(t, err)
+
+	url, err := m.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url.OriginalURL)
+
+	existed, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+	assert.ErrorIs(t, err, errs.ErrConflictOriginalURL)
+	assert.Equal(t, shortURL, existed)
+
+	_, err = m.Add(ctx, models.URL{ShortURL: shortURL, OriginalURL: "https://example.com"})
+	assert.ErrorIs(t, err, errs.ErrAliasTaken)
+
+	_, err = m.Get(ctx, "unknown")
+	assert.ErrorIs(t, err, errs.ErrNotFound)
+
+	t.Run("deleted url can be shortened again", func(t *testing.T) {
+		require.NoError(t, m.Delete(ctx, "user", []string{shortURL}))
+		_, err := m.Get(ctx, shortURL)
+		assert.ErrorIs(t, err, errs.ErrGone)
+
+		again, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+		require.NoError(t, err)
+		assert.NotEqual(t, shortURL, again)
+	})
+}
+
+func TestMemoryURLMapper_AddBatch(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryMapper()
+	existed, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru"})
+	require.NoError(t, err)
+
+	results, err := m.AddBatch(ctx, []models.URL{
+		{OriginalURL: "https://example.com", UserID: "user"},
+		{OriginalURL: "https://ya.ru", UserID: "user"},
+		{OriginalURL: "https://go.dev", ShortURL: "go-dev", UserID: "user"},
+		{OriginalURL: "https://example.com", UserID: "user"},
+		{OriginalURL: "", UserID: "user"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	assert.Equal(t, models.BatchCreated, results[0].Status)
+	assert.Equal(t, models.BatchResult{ShortURL: existed, Status: models.BatchExisting}, results[1])
+	assert.Equal(t, models.BatchResult{ShortURL: "go-dev", Status: models.BatchCreated}, results[2])
+	assert.Equal(t, models.BatchResult{ShortURL: results[0].ShortURL, Status: models.BatchExisting}, results[3])
+	assert.ErrorIs(t, results[4].Err, errs.ErrInvalidURL)
+
+	urls, err := m.GetByUserID(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	// занятый алиас портит только свой элемент, повтор адреса сохраняется без него
+	results, err = m.AddBatch(ctx, []models.URL{
+		{OriginalURL: "https://a.ru", ShortURL: "go-dev"},
+		{OriginalURL: "https://a.ru"},
+		{OriginalURL: "https://b.ru", ShortURL: "api"},
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, errs.ErrAliasTaken)
+	assert.Equal(t, models.BatchCreated, results[1].Status)
+	assert.ErrorIs(t, results[2].Err, errs.ErrReservedAlias)
+}
+
+func TestMemoryURLMapper_PurgeExpired(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryMapper()
+	expiresAt := time.Now().Add(time.Hour)
+	shortURL, err := m.Add(ctx, models.URL{OriginalURL: "https://ya.ru", UserID: "user", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	_, err = m.Add(ctx, models.URL{OriginalURL: "https://example.com", UserID: "user"})
+	require.NoError(t, err)
+
+	purged, err := m.PurgeExpired(ctx, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	url, err := m.Get(ctx, shortURL)
+	assert.ErrorIs(t, err, errs.ErrGone)
+	// владелец нужен, чтобы статистика вычищенной ссылки оставалась доступной автору
+	assert.Equal(t, "user", url.UserID)
+	// вычищенный код не выдаётся повторно
+	_, err = m.Add(ctx, models.URL{OriginalURL: "https://go.dev", ShortURL: shortURL})
+	assert.ErrorIs(t, err, errs.ErrAliasTaken)
+	stats, err := m.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.ServiceStats{URLs: 1, Users: 1}, *stats)
+}
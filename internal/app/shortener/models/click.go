@@ -0,0 +1,33 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type Click struct {
+	ShortURL  string    `json:"short_url"`
+	Time      time.Time `json:"time"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+}
+
+// Visitor возвращает обезличенный идентификатор посетителя для подсчёта уникальных переходов
+func (c Click) Visitor() string {
+	sum := sha256.Sum256([]byte(c.IP + "|" + c.UserAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+type DayClicks struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+type LinkStats struct {
+	ShortURL       string      `json:"short_url"`
+	TotalClicks    int64       `json:"total_clicks"`
+	UniqueVisitors int64       `json:"unique_visitors"`
+	Days           []DayClicks `json:"days"`
+}
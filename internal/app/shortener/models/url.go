@@ -1,7 +1,37 @@
 package models
 
+import "time"
+
 type URL struct {
 	ID          int
-	ShortURL    string `json:"short_url,omitempty"`
-	OriginalURL string `json:"original_url,omitempty"`
+	ShortURL    string     `json:"short_url,omitempty"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	DeletedFlag bool       `json:"is_deleted,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+func (u URL) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+}
+
+type ServiceStats struct {
+	URLs  int64 `json:"urls"`
+	Users int64 `json:"users"`
+}
+
+// BatchStatus - чем закончилось сохранение одного элемента пачки
+type BatchStatus string
+
+const (
+	BatchCreated  BatchStatus = "created"
+	BatchExisting BatchStatus = "existing"
+	BatchInvalid  BatchStatus = "invalid"
+)
+
+type BatchResult struct {
+	ShortURL string
+	Status   BatchStatus
+	// Err - причина, по которой элемент не сохранён, для BatchInvalid
+	Err error
 }
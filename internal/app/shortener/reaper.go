@@ -0,0 +1,80 @@
+package shortener
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const reaperTimeout = time.Minute
+
+type expiredPurger interface {
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Reaper периодически вычищает из хранилища ссылки с истёкшим сроком жизни
+type Reaper struct {
+	purger   expiredPurger
+	interval time.Duration
+
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+func NewReaper(purger expiredPurger, interval time.Duration) *Reaper {
+	return &Reaper{
+		purger:   purger,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (r *Reaper) Start() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.purge()
+			}
+		}
+	}()
+}
+
+// Stop останавливает фоновую очистку и дожидается завершения текущего прохода
+func (r *Reaper) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	r.wg.Wait()
+}
+
+func (r *Reaper) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), reaperTimeout)
+	defer cancel()
+
+	purged, err := r.purger.PurgeExpired(ctx, time.Now())
+	if err != nil {
+		logger.Log.Error("error to purge expired urls", zap.String("err", err.Error()))
+		return
+	}
+	if purged > 0 {
+		logger.Log.Info("expired urls purged", zap.Int64("count", purged))
+	}
+}
@@ -0,0 +1,184 @@
+package shortener
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+const (
+	removerWorkers       = 4
+	removerQueueSize     = 256
+	removerBatchSize     = 512
+	removerFlushInterval = time.Second
+	removerFlushTimeout  = 10 * time.Second
+)
+
+type urlDeleter interface {
+	Delete(ctx context.Context, userID string, shortURLs []string) error
+}
+
+type deleteTask struct {
+	userID    string
+	shortURLs []string
+}
+
+// URLRemover асинхронно помечает ссылки удалёнными.
+// Задачи из хендлеров разбирает пул воркеров, их результаты сводятся (fan-in)
+// в один канал, из которого флашер копит пачку и удаляет её одним запросом на пользователя.
+type URLRemover struct {
+	deleter urlDeleter
+	tasks   chan deleteTask
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewURLRemover(deleter urlDeleter) *URLRemover {
+	r := &URLRemover{
+		deleter: deleter,
+		tasks:   make(chan deleteTask, removerQueueSize),
+	}
+
+	workers := make([]chan deleteTask, 0, removerWorkers)
+	for i := 0; i < removerWorkers; i++ {
+		workers = append(workers, r.worker())
+	}
+
+	r.wg.Add(1)
+	go r.flush(fanIn(workers...))
+
+	return r
+}
+
+// Remove ставит ссылки пользователя в очередь на удаление и сразу возвращает управление.
+// Если очередь заполнена, задача не ставится и возвращается errs.ErrRemoverBusy.
+func (r *URLRemover) Remove(userID string, shortURLs []string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return errs.ErrRemoverClosed
+	}
+	select {
+	case r.tasks <- deleteTask{userID: userID, shortURLs: shortURLs}:
+		return nil
+	default:
+		return errs.ErrRemoverBusy
+	}
+}
+
+// Close перестаёт принимать задачи и дожидается удаления всего, что уже в очереди
+func (r *URLRemover) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.tasks)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// worker убирает дубликаты и пустые коды из задачи
+func (r *URLRemover) worker() chan deleteTask {
+	out := make(chan deleteTask)
+	go func() {
+		defer close(out)
+		for task := range r.tasks {
+			seen := make(map[string]struct{}, len(task.shortURLs))
+			shortURLs := task.shortURLs[:0]
+			for _, shortURL := range task.shortURLs {
+				if _, ok := seen[shortURL]; ok || shortURL == "" {
+					continue
+				}
+				seen[shortURL] = struct{}{}
+				shortURLs = append(shortURLs, shortURL)
+			}
+			if len(shortURLs) == 0 {
+				continue
+			}
+			out <- deleteTask{userID: task.userID, shortURLs: shortURLs}
+		}
+	}()
+	return out
+}
+
+func (r *URLRemover) flush(in chan deleteTask) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(removerFlushInterval)
+	defer ticker.Stop()
+
+	batch := make(map[string][]string)
+	size := 0
+	for {
+		select {
+		case task, ok := <-in:
+			if !ok {
+				r.deleteBatch(batch)
+				return
+			}
+			batch[task.userID] = append(batch[task.userID], task.shortURLs...)
+			size += len(task.shortURLs)
+			if size < removerBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		r.deleteBatch(batch)
+		batch = make(map[string][]string)
+		size = 0
+	}
+}
+
+func (r *URLRemover) deleteBatch(batch map[string][]string) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), removerFlushTimeout)
+	defer cancel()
+
+	for userID, shortURLs := range batch {
+		err := r.deleter.Delete(ctx, userID, shortURLs)
+		if err != nil {
+			logger.Log.Error(
+				"error to delete urls",
+				zap.String("user id", userID),
+				zap.Int("count", len(shortURLs)),
+				zap.String("err", err.Error()),
+			)
+		}
+	}
+}
+
+func fanIn(chs ...chan deleteTask) chan deleteTask {
+	out := make(chan deleteTask)
+
+	var wg sync.WaitGroup
+	for _, ch := range chs {
+		wg.Add(1)
+		go func(ch chan deleteTask) {
+			defer wg.Done()
+			for task := range ch {
+				out <- task
+			}
+		}(ch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,50 @@
+package shortener
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/app/server/errs"
+)
+
+// blockingDeleter не возвращается из Delete, пока не закрыт release
+type blockingDeleter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDeleter) Delete(context.Context, string, []string) error {
+	select {
+	case d.started <- struct{}{}:
+	default:
+	}
+	<-d.release
+	return nil
+}
+
+func TestURLRemover_queueFull(t *testing.T) {
+	deleter := &blockingDeleter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	r := NewURLRemover(deleter)
+
+	// полная пачка сразу уходит в Delete и занимает флашер
+	batch := make([]string, removerBatchSize)
+	for i := range batch {
+		batch[i] = strconv.Itoa(i)
+	}
+	require.NoError(t, r.Remove("user", batch))
+	<-deleter.started
+
+	var err error
+	for i := 0; i < removerQueueSize+2*removerWorkers+2 && err == nil; i++ {
+		err = r.Remove("user", []string{"code"})
+	}
+	assert.ErrorIs(t, err, errs.ErrRemoverBusy)
+
+	close(deleter.release)
+	r.Close()
+	assert.ErrorIs(t, r.Remove("user", []string{"code"}), errs.ErrRemoverClosed)
+}
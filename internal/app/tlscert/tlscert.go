@@ -0,0 +1,148 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const selfSignedValidity = 365 * 24 * time.Hour
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Config собирает tls.Config из минимальной версии ("1.2", "1.3") и списка шифров через запятую.
+// Пустой список оставляет набор шифров Go по умолчанию.
+func Config(minVersion string, cipherSuites string) (*tls.Config, error) {
+	version, ok := tlsVersions[strings.TrimSpace(minVersion)]
+	if !ok {
+		return nil, fmt.Errorf("unknown tls version %q", minVersion)
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(cipherSuites, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return &tls.Config{MinVersion: version, CipherSuites: suites}, nil
+}
+
+// DefaultDir - каталог, где кешируется самоподписанный сертификат для локальной разработки
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "go-yandex-shortener")
+}
+
+// EnsureSelfSigned возвращает пути к сертификату и ключу в dir,
+// создавая новую пару, если её нет или срок сертификата подходит к концу
+func EnsureSelfSigned(dir string, hosts []string) (string, string, error) {
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if valid(certPath, keyPath, time.Now().Add(24*time.Hour)) {
+		return certPath, keyPath, nil
+	}
+
+	certPEM, keyPEM, err := generate(hosts, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return "", "", err
+	}
+	return certPath, keyPath, nil
+}
+
+// valid проверяет, что пара читается и сертификат действует до момента until
+func valid(certPath string, keyPath string, until time.Time) bool {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+	return until.Before(cert.NotAfter)
+}
+
+func generate(hosts []string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-yandex-shortener dev"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range append([]string{"localhost", "127.0.0.1", "::1"}, hosts...) {
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,48 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+
+	certPath, keyPath, err := EnsureSelfSigned(dir, []string{"shortener.local"})
+	require.NoError(t, err)
+	_, err = tls.LoadX509KeyPair(certPath, keyPath)
+	require.NoError(t, err)
+
+	cert, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+
+	// повторный вызов берёт сертификат из кеша
+	_, _, err = EnsureSelfSigned(dir, []string{"shortener.local"})
+	require.NoError(t, err)
+	cached, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	assert.Equal(t, cert, cached)
+}
+
+func TestConfig(t *testing.T) {
+	cfg, err := Config("1.3", "")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.Empty(t, cfg.CipherSuites)
+
+	cfg, err = Config("1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	}, cfg.CipherSuites)
+
+	_, err = Config("2.0", "")
+	assert.Error(t, err)
+	_, err = Config("1.2", "TLS_RSA_WITH_RC4_128_SHA")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/AsakoKabe/go-yandex-shortener/internal/logger"
+)
+
+func TestMiddleware_continuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	core, logs := observer.New(zap.InfoLevel)
+	defaultLog := logger.Log
+	logger.Log = zap.New(core)
+	t.Cleanup(func() { logger.Log = defaultLog })
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "URLShortener.Get")
+		span.End()
+		logger.FromContext(r.Context()).Info("resolved")
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	child, server := spans[0], spans[1]
+
+	assert.Equal(t, "GET /{id}", server.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+	assert.Equal(t, server.SpanContext().SpanID().String(), fields["span_id"])
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// requestLog - логгер запроса и поля, которые попадут в итоговую строку о запросе
+type requestLog struct {
+	logger *zap.Logger
+	fields map[string]zap.Field
+}
+
+// FromContext возвращает логгер запроса, а вне запроса - Log с идентификаторами трейса из ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	if rl, ok := ctx.Value(ctxKey{}).(*requestLog); ok {
+		return rl.logger
+	}
+	return withTrace(ctx, Log)
+}
+
+// With добавляет поля к логгеру запроса и к итоговой строке о запросе
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	rl, ok := ctx.Value(ctxKey{}).(*requestLog)
+	if !ok {
+		return ctx
+	}
+	for _, field := range fields {
+		rl.fields[field.Key] = field
+	}
+	child := &requestLog{logger: rl.logger.With(fields...), fields: rl.fields}
+	return context.WithValue(ctx, ctxKey{}, child)
+}
+
+func withTrace(ctx context.Context, l *zap.Logger) *zap.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.With(
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	)
+}
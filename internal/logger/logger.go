@@ -1,19 +1,40 @@
 package logger
 
 import (
-	"github.com/go-chi/chi/v5/middleware"
+	"fmt"
+	"net/http"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"net/http"
-	"strconv"
-	"time"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
 )
 
 var Log = zap.NewNop()
 
-func Initialize(level zapcore.Level) error {
-	cfg := zap.NewProductionConfig()
-	cfg.Level = zap.NewAtomicLevelAt(level)
+// level общий для всех логгеров, его можно менять на лету через LevelHandler
+var level = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+func Initialize(logLevel string, format string) error {
+	lvl, err := zapcore.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(lvl)
+
+	var cfg zap.Config
+	switch format {
+	case "", FormatJSON:
+		cfg = zap.NewProductionConfig()
+	case FormatConsole:
+		cfg = zap.NewDevelopmentConfig()
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	cfg.Level = level
 	zl, err := cfg.Build()
 	if err != nil {
 		return err
@@ -22,29 +43,7 @@ func Initialize(level zapcore.Level) error {
 	return nil
 }
 
-func RequestLogger(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-
-			t1 := time.Now()
-			defer func() {
-				Log.Info(
-					"request",
-					zap.String("URI", r.RequestURI),
-					zap.String("method", r.Method),
-					zap.String("elapsed", strconv.FormatInt(int64(time.Since(t1)), 10)),
-				)
-				Log.Info(
-					"response",
-					zap.String("status", strconv.Itoa(ww.Status())),
-					zap.String("bytes", strconv.Itoa(ww.BytesWritten())),
-				)
-				//entry.Write(ww.Status(), ww.BytesWritten(), ww.Header(), time.Since(t1), nil)
-			}()
-
-			next.ServeHTTP(ww, r)
-		}
-		return http.HandlerFunc(fn)
-	}(next)
+// LevelHandler отдаёт текущий уровень на GET и меняет его на PUT с телом {"level":"debug"}
+func LevelHandler() http.Handler {
+	return level
 }
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestLogger выдаёт запросу X-Request-ID, кладёт в контекст логгер запроса
+// и по завершении пишет одну строку о запросе
+func RequestLogger(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		rl := &requestLog{
+			logger: withTrace(r.Context(), Log).With(zap.String("request_id", requestID)),
+			fields: map[string]zap.Field{"remote_ip": zap.String("remote_ip", peerIP(r.RemoteAddr))},
+		}
+		ctx := context.WithValue(r.Context(), ctxKey{}, rl)
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("uri", r.RequestURI),
+			zap.String("route", route),
+			zap.Int("status", status),
+			zap.Int("bytes", ww.BytesWritten()),
+			zap.Duration("duration", time.Since(start)),
+		}
+		for _, field := range rl.fields {
+			fields = append(fields, field)
+		}
+		rl.logger.Info("request", fields...)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// RequestIDFromContext возвращает X-Request-ID текущего запроса
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
+
+// validRequestID принимает идентификатор клиента, только если его безопасно писать в логи и заголовки
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func peerIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
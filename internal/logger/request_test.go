@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func observe(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zap.DebugLevel)
+	defaultLog := Log
+	Log = zap.New(core)
+	t.Cleanup(func() { Log = defaultLog })
+	return logs
+}
+
+func TestRequestLogger(t *testing.T) {
+	logs := observe(t)
+
+	router := chi.NewRouter()
+	router.Use(RequestLogger)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(With(r.Context(), zap.String("user_id", "u1"))))
+		})
+	})
+	router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Warn("not found")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	tests := []struct {
+		name      string
+		requestID string
+		reuse     bool
+	}{
+		{name: "request id from client", requestID: "abc-123", reuse: true},
+		{name: "generated request id", requestID: ""},
+		{name: "unsafe request id replaced", requestID: strings.Repeat("x", 200)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			request.RemoteAddr = "192.0.2.1:1234"
+			request.Header.Set(RequestIDHeader, test.requestID)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, request)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			require.NotEmpty(t, requestID)
+			if test.reuse {
+				assert.Equal(t, test.requestID, requestID)
+			} else {
+				assert.NotEqual(t, test.requestID, requestID)
+			}
+
+			entries := logs.TakeAll()
+			require.Len(t, entries, 2)
+			handlerLine, requestLine := entries[0].ContextMap(), entries[1].ContextMap()
+			assert.Equal(t, requestID, handlerLine["request_id"])
+			assert.Equal(t, "u1", handlerLine["user_id"])
+
+			assert.Equal(t, "request", entries[1].Message)
+			assert.Equal(t, requestID, requestLine["request_id"])
+			assert.Equal(t, "/{id}", requestLine["route"])
+			assert.Equal(t, int64(http.StatusNotFound), requestLine["status"])
+			assert.Equal(t, "u1", requestLine["user_id"])
+			assert.Equal(t, "192.0.2.1", requestLine["remote_ip"])
+			assert.IsType(t, time.Duration(0), requestLine["duration"])
+		})
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	t.Cleanup(func() { level.SetLevel(zapcore.InfoLevel) })
+
+	w := httptest.NewRecorder()
+	LevelHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug"}`)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+}